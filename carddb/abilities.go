@@ -0,0 +1,101 @@
+package carddb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type abilityJSON struct {
+	ID          uint64 `json:"id"`
+	TextID      uint64 `json:"text"`
+	BaseID      uint64 `json:"baseId"`
+	Category    uint64 `json:"category"`
+	SubCategory uint64 `json:"subCategory"`
+	AbilityWord uint64 `json:"abilityWord"`
+}
+
+// Ability is one of the rules abilities of a card.
+type Ability struct {
+	ID     uint64
+	TextID uint64
+	// KeywordID is the ability ID of the keyword this ability is an instance of (e.g. Flying),
+	// or 0 if the ability is not a keyword ability.
+	KeywordID uint64
+	// Keyword is the name of the keyword for KeywordID.
+	Keyword string
+	// Text is the rules text of the ability, with CARDNAME replaced by the name of the card.
+	Text string
+}
+
+func parseAbilitiesFile(abilitiesFile io.Reader) (map[uint64]abilityJSON, error) {
+	decoder := json.NewDecoder(abilitiesFile)
+	var abilities []abilityJSON
+	if err := decoder.Decode(&abilities); err != nil {
+		return nil, fmt.Errorf("Failed to decode JSON: %v", err)
+	}
+
+	m := make(map[uint64]abilityJSON)
+	for _, a := range abilities {
+		m[a.ID] = a
+	}
+	return m, nil
+}
+
+// resolveAbilities returns the abilities of card, with their texts resolved. abilities might be empty,
+// in which case the keyword information is not available.
+func resolveAbilities(card *Card, abilities map[uint64]abilityJSON, texts map[uint64]string) []Ability {
+	var res []Ability
+	for _, ref := range card.Abilities {
+		a := Ability{
+			ID:     ref.AbilityID,
+			TextID: ref.TextID,
+			Text:   strings.ReplaceAll(texts[ref.TextID], "CARDNAME", card.Name),
+		}
+		if aj, ok := abilities[ref.AbilityID]; ok && aj.BaseID != 0 {
+			a.KeywordID = aj.BaseID
+			if base, ok := abilities[aj.BaseID]; ok {
+				a.Keyword = texts[base.TextID]
+			}
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+func rulesText(abilities []Ability) string {
+	ls := make([]string, 0, len(abilities))
+	for _, a := range abilities {
+		if a.Text == "" {
+			continue
+		}
+		ls = append(ls, a.Text)
+	}
+	return strings.Join(ls, "\n")
+}
+
+// HasKeyword returns whether the card has the given keyword ability (e.g. "Flying").
+// The comparison is case insensitive.
+func (c *Card) HasKeyword(keyword string) bool {
+	for _, a := range c.Rules {
+		if a.Keyword != "" && strings.EqualFold(a.Keyword, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasKeywordID returns whether the card has an ability that is an instance of the given keyword.
+// Abilities that are not keywords have a KeywordID of 0, and HasKeywordID(0) is always false.
+func (c *Card) HasKeywordID(keywordID uint64) bool {
+	if keywordID == 0 {
+		return false
+	}
+	for _, a := range c.Rules {
+		if a.KeywordID == keywordID {
+			return true
+		}
+	}
+	return false
+}
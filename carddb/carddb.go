@@ -7,9 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"os"
-	"path/filepath"
+	"strings"
 )
 
 const (
@@ -27,27 +25,96 @@ const (
 	TokenRarity = 0
 )
 
+// setNumber identifies a printing of a card: its set and collector number.
+type setNumber struct {
+	set    string // upper case set code.
+	number string
+}
+
 type cardDB struct {
-	byName   map[string][]*Card
-	texts    map[uint64]string
-	cardList []Card
-	byID     map[uint64]*Card
+	lang            string             // language code of the texts used for the cards.
+	byName          map[string][]*Card // names in lang.
+	byLocalizedName map[string][]*Card // names in every language.
+	normalized      *nameIndex         // normalized names in every language.
+	texts           map[uint64]string
+	locs            localizations
+	res             *resources // the resources the database was created from.
+	enums           enums
+	cardList        []Card
+	byID            map[uint64]*Card
+	byTitleID       map[uint64][]*Card
+	bySet           map[string][]*Card // keyed by the upper case set code.
+	bySetNumber     map[setNumber]*Card
+	creators        map[uint64][]*Card // cards that create each token, by token ID.
 }
 
 // CardJSON is the JSON representation of a card, as it appears in the MTGA Resource files.
 type CardJSON struct {
-	ID              uint64   `json:"grpid"`
-	TitleID         uint64   `json:"titleId"`
-	CollectorNumber string   `json:"CollectorNumber"`
-	Set             string   `json:"set"`
-	Rarity          uint64   `json:"rarity"`
-	ColorIdentity   []uint64 `json:"colorIdentity"`
-	CastingCost     string   `json:"castingcost"`
-	Types           []uint64 `json:"types"`
-	Subtypes        []uint64 `json:"subtypes"`
-	Supertypes      []uint64 `json:"supertypes"`
-	CardTypeTextID  uint64   `json:"cardTypeTextId"`
-	SubtypeTextID   uint64   `json:"subtypeTextId"`
+	ID                   uint64           `json:"grpid"`
+	TitleID              uint64           `json:"titleId"`
+	ArtID                uint64           `json:"artId"`
+	ArtSize              uint64           `json:"artSize"`
+	FlavorID             uint64           `json:"flavorId"`
+	ArtistCredit         string           `json:"artistCredit"`
+	CollectorNumber      string           `json:"CollectorNumber"`
+	Set                  string           `json:"set"`
+	Rarity               uint64           `json:"rarity"`
+	IsToken              bool             `json:"isToken"`
+	IsCollectible        bool             `json:"isCollectible"`
+	IsCraftable          bool             `json:"isCraftable"`
+	AltDeckLimit         *uint64          `json:"altDeckLimit"` // nil unless the card ignores the 4-copies rule.
+	Cmc                  uint64           `json:"cmc"`
+	Power                CardStat         `json:"power"`
+	Toughness            CardStat         `json:"toughness"`
+	Colors               []uint64         `json:"colors"`
+	FrameColors          []uint64         `json:"frameColors"`
+	FrameDetails         []string         `json:"frameDetails"`
+	ColorIdentity        []uint64         `json:"colorIdentity"`
+	CastingCost          string           `json:"castingcost"`
+	Types                []uint64         `json:"types"`
+	Subtypes             []uint64         `json:"subtypes"`
+	Supertypes           []uint64         `json:"supertypes"`
+	CardTypeTextID       uint64           `json:"cardTypeTextId"`
+	SubtypeTextID        uint64           `json:"subtypeTextId"`
+	Abilities            []CardAbilityRef `json:"abilities"`
+	HiddenAbilities      []CardAbilityRef `json:"hiddenAbilities"`
+	LinkedFaceType       uint64           `json:"linkedFaceType"`
+	LinkedFaces          []uint64         `json:"linkedFaces"`
+	LinkedTokens         []uint64         `json:"linkedTokens"`
+	KnownSupportedStyles []string         `json:"knownSupportedStyles"`
+}
+
+// CardAbilityRef is a reference to an ability of a card. The text of the ability can be found
+// in the texts file under TextID.
+type CardAbilityRef struct {
+	AbilityID uint64 `json:"abilityId"`
+	TextID    uint64 `json:"textId"`
+}
+
+// CardStat is the power or toughness of a card. The resource files store them as numbers, but some
+// cards have values like "*" or "1+*", so they are kept as strings.
+type CardStat string
+
+// UnmarshalJSON accepts both JSON numbers and JSON strings.
+func (s *CardStat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*s = CardStat(str)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid card stat %s: %v", data, err)
+	}
+	*s = CardStat(n.String())
+	return nil
 }
 
 type textJSON struct {
@@ -61,36 +128,161 @@ type langJSON struct {
 	Keys    []textJSON `json:"keys"`
 }
 
+// localization holds all the texts for a language.
+type localization struct {
+	IsoCode string
+	LangKey string
+	Texts   map[uint64]string
+}
+
+// code returns the code used to identify the language, preferring the iso-code.
+func (l *localization) code() string {
+	if l.IsoCode != "" {
+		return l.IsoCode
+	}
+	return l.LangKey
+}
+
+type localizations []localization
+
+// find returns the localization for lang, which can be either an iso-code ("es-ES") or a lang key ("ES").
+func (ls localizations) find(lang string) *localization {
+	for i := range ls {
+		if strings.EqualFold(ls[i].IsoCode, lang) || strings.EqualFold(ls[i].LangKey, lang) {
+			return &ls[i]
+		}
+	}
+	return nil
+}
+
 // Card represents an Magic The Gathering: Arena card.
 // It comes with the name and with the JSON representation of the card.
 type Card struct {
 	Name string
+	// TypeLine is the type line as printed in the card, e.g. "Creature — Elf Warrior".
+	TypeLine string
+	// SupertypeNames, TypeNames and SubtypeNames are the names for the Supertypes, Types and Subtypes
+	// of the card. They are only available if the library was created with an enums file.
+	SupertypeNames []string
+	TypeNames      []string
+	SubtypeNames   []string
+	// Rules are the abilities of the card, with their text resolved. The keyword information is only
+	// available if the library was created with an abilities file.
+	Rules []Ability
+	// RulesText is the rules text of the card, one ability per line.
+	RulesText string
+	// Faces are the other faces of the card (the back of a double faced card, the halves of a split
+	// card, etc). See LinkType for the role of this card among them.
+	Faces []*Card
+	// Tokens are the tokens the card creates. See AllTokens for the tokens of all the faces.
+	Tokens []*Card
+	// ManaCost is the parsed CastingCost.
+	ManaCost ManaCost
 	CardJSON
 }
 
 // CardDB lets you interact with a Magic The Gathering: Arena card database.
 type CardDB interface {
-	// Returns all the cards with the given name.
+	// Returns all the cards with the given name. The name can be in any of the languages in the database.
+	// If there's no exact match, the name is compared ignoring case, diacritics and punctuation, and
+	// split cards can be found by the name of each half.
 	GetCard(name string) []*Card
 
+	// SuggestNames returns up to max card names that are similar to name, best matches first.
+	SuggestNames(name string, max int) []string
+
 	// Returns the card with the given ID, nil if it doesn't exist.
 	GetCardByID(id uint64) *Card
 
+	// Returns the card with the given set and collector number (e.g. "THB", "251"), nil if it doesn't exist.
+	// The set code is case insensitive.
+	GetCardBySetNumber(set string, collectorNumber string) *Card
+
+	// Returns all the cards in the given set.
+	CardsInSet(set string) []*Card
+
+	// Returns all the cards with the given title ID, that is, all the printings of a card.
+	GetCardsByTitleID(titleID uint64) []*Card
+
+	// TokenCreators returns the cards that create the token with the given ID.
+	TokenCreators(tokenID uint64) []*Card
+
+	// CardsCreatingToken returns the cards that create a token with the given name, like "Food".
+	CardsCreatingToken(name string) []*Card
+
 	// ForEach runs f over each card in the database
 	ForEach(f func(Card))
 
 	// Returns all the cards that match the given predicate
 	Filter(predicate func(Card) bool) []Card
+
+	// Query returns all the cards that match the given query. See ParseQuery for the syntax.
+	Query(query string) ([]Card, error)
+
+	// Language returns the language code used for the card names and texts.
+	Language() string
+
+	// Languages returns the codes for all the languages in the database.
+	Languages() []string
+
+	// LocalizedName returns the name of the card in the given language, or the card name if there is
+	// no translation for it.
+	LocalizedName(c *Card, lang string) string
 }
 
 func (db *cardDB) GetCard(name string) []*Card {
-	return db.byName[name]
+	if cs, ok := db.byName[name]; ok {
+		return cs
+	}
+	if cs, ok := db.byLocalizedName[name]; ok {
+		return cs
+	}
+	return db.normalized.get(name)
+}
+
+func (db *cardDB) SuggestNames(name string, max int) []string {
+	return db.normalized.suggest(name, max)
+}
+
+func (db *cardDB) Language() string {
+	return db.lang
+}
+
+func (db *cardDB) Languages() []string {
+	ls := make([]string, 0, len(db.locs))
+	for i := range db.locs {
+		ls = append(ls, db.locs[i].code())
+	}
+	return ls
+}
+
+func (db *cardDB) LocalizedName(c *Card, lang string) string {
+	loc := db.locs.find(lang)
+	if loc == nil {
+		return c.Name
+	}
+	if name, ok := loc.Texts[c.TitleID]; ok {
+		return name
+	}
+	return c.Name
 }
 
 func (db *cardDB) GetCardByID(id uint64) *Card {
 	return db.byID[id]
 }
 
+func (db *cardDB) GetCardBySetNumber(set string, collectorNumber string) *Card {
+	return db.bySetNumber[setNumber{strings.ToUpper(set), collectorNumber}]
+}
+
+func (db *cardDB) CardsInSet(set string) []*Card {
+	return db.bySet[strings.ToUpper(set)]
+}
+
+func (db *cardDB) GetCardsByTitleID(titleID uint64) []*Card {
+	return db.byTitleID[titleID]
+}
+
 func (db *cardDB) Filter(predicate func(c Card) bool) []Card {
 	ls := []Card{}
 
@@ -110,66 +302,201 @@ func (db *cardDB) ForEach(f func(Card)) {
 	}
 }
 
+// ResourceFiles holds the MTGA resource files used to create a card database. Cards and Texts are
+// required, the rest of the files are optional and are only used to add extra information to the cards.
+type ResourceFiles struct {
+	Cards     io.Reader // data_cards_<hash>.mtga
+	Texts     io.Reader // data_loc_<hash>.mtga
+	Enums     io.Reader // data_enums_<hash>.mtga
+	Abilities io.Reader // data_abilities_<hash>.mtga
+}
+
 // NewLibrary creates a new database of magic cards. It needs to use the files that are used by MTG Arena
 // that describes the cards in JSON format. They are commonly named data_cards_<hash> and data_loc_<hash>
 // The language is the iso-code for the language on the card names that you would like (probably "en-US").
+// All the other languages in the texts file are kept, so cards can be looked up in any of them.
 func NewLibrary(cardsFile io.Reader, textsFile io.Reader, textsLang string) (CardDB, error) {
-	cards, err := parseCardsFile(cardsFile)
+	return NewLibraryFromResources(ResourceFiles{Cards: cardsFile, Texts: textsFile}, textsLang)
+}
+
+// NewLibraryFromResources is like NewLibrary, but it also uses the optional resource files in files.
+func NewLibraryFromResources(files ResourceFiles, textsLang string) (CardDB, error) {
+	res, err := parseResources(files)
+	if err != nil {
+		return nil, err
+	}
+	db, err := newCardDB(res, textsLang)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// resources has the parsed contents of the resource files. Everything in the card database is derived
+// from it.
+type resources struct {
+	Cards     []CardJSON
+	Locs      localizations
+	Enums     enums
+	Abilities map[uint64]abilityJSON
+}
+
+func parseResources(files ResourceFiles) (*resources, error) {
+	var err error
+	res := &resources{
+		Enums:     make(enums),
+		Abilities: make(map[uint64]abilityJSON),
+	}
+	res.Cards, err = parseCardsFile(files.Cards)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse cards file: %v", err)
 	}
-	texts, err := parseTextsFile(textsFile, textsLang)
+	res.Locs, err = parseLocalizations(files.Texts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse texts file: %v", err)
 	}
+	if files.Enums != nil {
+		res.Enums, err = parseEnumsFile(files.Enums)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse enums file: %v", err)
+		}
+	}
+	if files.Abilities != nil {
+		res.Abilities, err = parseAbilitiesFile(files.Abilities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse abilities file: %v", err)
+		}
+	}
+	return res, nil
+}
 
-	// cards is a list with all the cards. Really we would like to index it by the card name.
-	// However, we might have multiple cards with the same name for different expansions, so
-	// we are going to have to get all the versions.
-	cardList := []Card{}
-	byName := make(map[string][]*Card)
+// newCardDB creates the card database for res, with the card names and texts in lang.
+func newCardDB(res *resources, lang string) (*cardDB, error) {
+	cards, locs, es, abilities := res.Cards, res.Locs, res.Enums, res.Abilities
+	loc := locs.find(lang)
+	if loc == nil {
+		return nil, fmt.Errorf("language %q not found in texts file", lang)
+	}
+	texts := loc.Texts
+
+	// The indexes point to the cards in cardList, so it can't grow after this.
+	cardList := make([]Card, 0, len(cards))
 	byID := make(map[uint64]*Card)
 	for i, cardjson := range cards {
 		name, ok := texts[cardjson.TitleID]
 		if !ok {
 			return nil, fmt.Errorf("Missing card text for card %d", cardjson.ID)
 		}
-		card := Card{name, cardjson}
+		card := Card{Name: name, CardJSON: cardjson}
+		card.SupertypeNames = es.names(supertypeEnum, card.Supertypes, texts)
+		card.TypeNames = es.names(cardTypeEnum, card.Types, texts)
+		card.SubtypeNames = es.names(subtypeEnum, card.Subtypes, texts)
+		card.TypeLine = typeLine(&card, texts)
+		card.Rules = resolveAbilities(&card, abilities, texts)
+		card.RulesText = rulesText(card.Rules)
+		// Costs with symbols we don't know about are left empty, the raw cost is still in CastingCost.
+		card.ManaCost, _ = ParseManaCost(card.CastingCost)
 		cardList = append(cardList, card)
-		if _, ok := byName[name]; !ok {
-			byName[name] = []*Card{}
-		}
 		byID[card.ID] = &cardList[i]
-		byName[name] = append(byName[name], &cardList[i])
+	}
+	linkFaces(cardList, byID)
+	creators := linkTokens(cardList, byID)
+
+	// Really we would like to index the cards by name. However, we might have multiple cards with
+	// the same name for different expansions, so we are going to have to get all the versions.
+	// Secondary faces are indexed under their primary face, so looking up the name of the back of a
+	// card returns the card itself.
+	byName := make(map[string][]*Card)
+	normalized := newNameIndex()
+	for i := range cardList {
+		card := &cardList[i]
+		primary := card.PrimaryFace()
+		names := []string{card.Name}
+		if card == primary {
+			names = append(names, card.FullName())
+		}
+		for _, name := range names {
+			if !containsCard(byName[name], primary) {
+				byName[name] = append(byName[name], primary)
+			}
+			normalized.add(name, primary)
+		}
+	}
+	byLocalizedName := make(map[string][]*Card)
+	for i := range locs {
+		for j := range cardList {
+			card := &cardList[j]
+			primary := card.PrimaryFace()
+			name, ok := locs[i].Texts[card.TitleID]
+			if !ok || containsCard(byLocalizedName[name], primary) {
+				continue
+			}
+			byLocalizedName[name] = append(byLocalizedName[name], primary)
+			normalized.add(name, primary)
+		}
+	}
+
+	byTitleID := make(map[uint64][]*Card)
+	bySet := make(map[string][]*Card)
+	bySetNumber := make(map[setNumber]*Card)
+	for i := range cardList {
+		card := &cardList[i]
+		set := strings.ToUpper(card.Set)
+		byTitleID[card.TitleID] = append(byTitleID[card.TitleID], card)
+		bySet[set] = append(bySet[set], card)
+		if card.CollectorNumber == "" {
+			continue
+		}
+		// Tokens and the other faces of a card sometimes share the collector number with it.
+		key := setNumber{set, card.CollectorNumber}
+		if prev, ok := bySetNumber[key]; !ok || (isSecondaryPrinting(prev) && !isSecondaryPrinting(card)) {
+			bySetNumber[key] = card
+		}
 	}
 
 	return &cardDB{
-		byName:   byName,
-		byID:     byID,
-		cardList: cardList,
-		texts:    texts,
+		lang:            loc.code(),
+		byName:          byName,
+		byLocalizedName: byLocalizedName,
+		normalized:      normalized,
+		byID:            byID,
+		byTitleID:       byTitleID,
+		bySet:           bySet,
+		bySetNumber:     bySetNumber,
+		creators:        creators,
+		cardList:        cardList,
+		texts:           texts,
+		locs:            locs,
+		enums:           es,
+		res:             res,
 	}, nil
 }
 
-func parseTextsFile(textsFile io.Reader, lang string) (map[uint64]string, error) {
+func containsCard(ls []*Card, c *Card) bool {
+	for _, v := range ls {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+func parseLocalizations(textsFile io.Reader) (localizations, error) {
 	decoder := json.NewDecoder(textsFile)
 	var texts []langJSON
 	if err := decoder.Decode(&texts); err != nil {
 		return nil, fmt.Errorf("Failed to decode JSON: %v", err)
 	}
 
-	m := make(map[uint64]string)
+	locs := make(localizations, 0, len(texts))
 	for _, v := range texts {
-		if v.IsoCode != lang {
-			log.Printf("Skipping language %q", v.IsoCode)
-			continue
-		}
+		m := make(map[uint64]string)
 		for _, kv := range v.Keys {
 			m[kv.ID] = kv.Text
 		}
-		break
+		locs = append(locs, localization{IsoCode: v.IsoCode, LangKey: v.LangKey, Texts: m})
 	}
-	return m, nil
+	return locs, nil
 }
 
 func parseCardsFile(cardsFile io.Reader) ([]CardJSON, error) {
@@ -180,56 +507,3 @@ func parseCardsFile(cardsFile io.Reader) ([]CardJSON, error) {
 	}
 	return cards, nil
 }
-
-// FindMTGAResourceFiles returns the paths for the resource files needed by carddb, given the Data
-// path for MTG Arena. Typically the files are stored in the mtgDataPath folder, but their names have a hash
-// at the end. This function just try to look in that folder for the correct files.
-func findMTGAResourceFiles(mtgDataPath string) (cardsFilePath string, textsFilePath string, err error) {
-	mtgDataTextsFileGlob := filepath.Join(mtgDataPath, "data_loc_"+"*"+".mtga")
-	mtgDataCardsFileGlob := filepath.Join(mtgDataPath, "data_cards_"+"*"+".mtga")
-
-	textsFiles, err := filepath.Glob(mtgDataTextsFileGlob)
-	if err != nil {
-		err = fmt.Errorf("Failed to look for texts file: %v", err)
-		return
-	}
-	if len(textsFiles) != 1 {
-		err = fmt.Errorf("More than one texts file found: %v", textsFiles)
-		return
-	}
-
-	cardsFiles, err := filepath.Glob(mtgDataCardsFileGlob)
-	if err != nil {
-		err = fmt.Errorf("Failed to look for cards file: %v", err)
-		return
-	}
-	if len(cardsFiles) != 1 {
-		err = fmt.Errorf("More than one cards file found: %v", cardsFiles)
-		return
-	}
-
-	cardsFilePath, textsFilePath = cardsFiles[0], textsFiles[0]
-	return
-}
-
-// CreateLibrary is a helper function for NewLibrary, it takes the Data path inside the MTG installation
-// directory, and tries to find the required resource files for creating the database.
-func CreateLibrary(mtgDataPath string) (CardDB, error) {
-	cardsPath, textsPath, err := findMTGAResourceFiles(mtgDataPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find mtga resource files: %v", err)
-	}
-	cardsFile, err := os.Open(cardsPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open cards resource file: %v", err)
-	}
-	defer cardsFile.Close()
-	textsFile, err := os.Open(textsPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open texts resource file: %v", err)
-	}
-	defer textsFile.Close()
-
-	db, err := NewLibrary(cardsFile, textsFile, "en-US")
-	return db, err
-}
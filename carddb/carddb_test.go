@@ -1,6 +1,7 @@
 package carddb
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -86,14 +87,70 @@ func TestParseCardsFile(t *testing.T) {
 	}
 }
 
-func TestParseTextsFile(t *testing.T) {
-	r := strings.NewReader(testTextsJSON)
+func TestParseCardsFileFields(t *testing.T) {
+	r := strings.NewReader(testCardsJSON)
 
-	wantEN := map[uint64]string{1: "TEST1", 2: "TEST2", 3: "TEST3"}
-	texts, err := parseTextsFile(r, "EN")
+	cards, err := parseCardsFile(r)
+	if err != nil {
+		t.Fatalf("failed to parse cards file: %v", err)
+	}
+
+	c := cards[0]
+	if c.Cmc != 8 || c.Power != "8" || c.Toughness != "4" {
+		t.Errorf("wrong cmc/power/toughness. want 8 8/4, got %d %s/%s", c.Cmc, c.Power, c.Toughness)
+	}
+	if len(c.Colors) != 1 || c.Colors[0] != 5 {
+		t.Errorf("wrong colors. want [5], got %v", c.Colors)
+	}
+	if len(c.Abilities) != 1 || c.Abilities[0].AbilityID != 7 || c.Abilities[0].TextID != 7 {
+		t.Errorf("wrong abilities. want [{7 7}], got %v", c.Abilities)
+	}
+	if c.AltDeckLimit != nil {
+		t.Errorf("wrong altDeckLimit. want nil, got %d", *c.AltDeckLimit)
+	}
+	if c.ArtistCredit != "John Doe" {
+		t.Errorf("wrong artist credit. want %q, got %q", "John Doe", c.ArtistCredit)
+	}
+
+	c = cards[1]
+	if c.AltDeckLimit == nil || *c.AltDeckLimit != 250 {
+		t.Errorf("wrong altDeckLimit. want 250, got %v", c.AltDeckLimit)
+	}
+	if !c.IsCollectible || c.IsCraftable || c.IsToken {
+		t.Errorf("wrong flags. want collectible, not craftable, not token. got %+v", c)
+	}
+
+	c = cards[2]
+	if len(c.Abilities) != 3 {
+		t.Errorf("wrong number of abilities. want 3, got %d", len(c.Abilities))
+	}
+	if len(c.KnownSupportedStyles) != 1 || c.KnownSupportedStyles[0] != "DA" {
+		t.Errorf("wrong styles. want [DA], got %v", c.KnownSupportedStyles)
+	}
+}
+
+func TestCardStatStrings(t *testing.T) {
+	var c CardJSON
+	if err := json.Unmarshal([]byte(`{"power": "*", "toughness": "1+*"}`), &c); err != nil {
+		t.Fatalf("failed to decode card: %v", err)
+	}
+	if c.Power != "*" || c.Toughness != "1+*" {
+		t.Errorf("wrong stats. want */1+*, got %s/%s", c.Power, c.Toughness)
+	}
+}
+
+func TestParseLocalizations(t *testing.T) {
+	locs, err := parseLocalizations(strings.NewReader(testTextsJSON))
 	if err != nil {
 		t.Fatalf("failed to parse texts file: %v", err)
 	}
+
+	wantEN := map[uint64]string{1: "TEST1", 2: "TEST2", 3: "TEST3"}
+	loc := locs.find("EN")
+	if loc == nil {
+		t.Fatalf("no texts for EN")
+	}
+	texts := loc.Texts
 	if len(texts) != len(wantEN) {
 		t.Fatalf("wrong number of texts. Want %d, got %d", len(wantEN), len(texts))
 	}
@@ -103,12 +160,12 @@ func TestParseTextsFile(t *testing.T) {
 			t.Errorf("wrong text for key %d. want %q, got %q", k, v, texts[k])
 		}
 	}
-	r = strings.NewReader(testTextsJSON)
 	wantES := map[uint64]string{1: "PRUEBA1", 2: "PRUEBA2", 3: "PRUEBA3"}
-	texts, err = parseTextsFile(r, "ES")
-	if err != nil {
-		t.Fatalf("failed to parse texts file: %v", err)
+	loc = locs.find("ES")
+	if loc == nil {
+		t.Fatalf("no texts for ES")
 	}
+	texts = loc.Texts
 	if len(texts) != len(wantES) {
 		t.Fatalf("wrong number of texts. Want %d, got %d", len(wantES), len(texts))
 	}
@@ -164,3 +221,324 @@ func TestLibrary(t *testing.T) {
 		t.Errorf("Card By Name and by ID mismatch")
 	}
 }
+
+func TestLibraryEnums(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 100, "set": "M19", "rarity": 2, "castingcost": "oG", "colors": [5], "colorIdentity": [5],
+		 "types": [2], "subtypes": [30, 31], "supertypes": [], "cardTypeTextId": 200, "subtypeTextId": 201},
+		{"grpid": 2, "titleId": 101, "set": "WAR", "rarity": 4, "castingcost": "o3oBoBoB", "colors": [3, 1], "colorIdentity": [1, 3],
+		 "types": [1], "subtypes": [], "supertypes": [2]}
+	]`
+	var textsJSON = `[
+		{ "isoCode": "en-US", "keys" : [
+			{"id": 100, "text": "Llanowar Elves"},
+			{"id": 101, "text": "Some Artifact"},
+			{"id": 200, "text": "Creature"},
+			{"id": 201, "text": "Elf Druid"},
+			{"id": 300, "text": "Artifact"},
+			{"id": 301, "text": "Creature"},
+			{"id": 302, "text": "Elf"},
+			{"id": 303, "text": "Druid"},
+			{"id": 304, "text": "Legendary"}
+		]}
+	]`
+	var enumsJSON = `[
+		{"name": "CardType", "values": [{"id": 1, "text": 300}, {"id": 2, "text": 301}]},
+		{"name": "SubType", "values": [{"id": 30, "text": 302}, {"id": 31, "text": 303}]},
+		{"name": "SuperType", "values": [{"id": 2, "text": 304}]}
+	]`
+
+	res := ResourceFiles{
+		Cards: strings.NewReader(cardsJSON),
+		Texts: strings.NewReader(textsJSON),
+		Enums: strings.NewReader(enumsJSON),
+	}
+	db, err := NewLibraryFromResources(res, "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	elves := db.GetCardByID(1)
+	if elves.TypeLine != "Creature — Elf Druid" {
+		t.Errorf("wrong type line. want %q, got %q", "Creature — Elf Druid", elves.TypeLine)
+	}
+	if !elves.HasType("creature") || !elves.HasSubtype("Elf") || elves.HasType("Artifact") {
+		t.Errorf("wrong types for %q: %v %v", elves.Name, elves.TypeNames, elves.SubtypeNames)
+	}
+	if !elves.HasColor(Green) || elves.ColorString() != "G" {
+		t.Errorf("wrong colors for %q: %q", elves.Name, elves.ColorString())
+	}
+
+	artifact := db.GetCardByID(2)
+	if artifact.TypeLine != "Legendary Artifact" {
+		t.Errorf("wrong type line. want %q, got %q", "Legendary Artifact", artifact.TypeLine)
+	}
+	if !artifact.HasType("Legendary") {
+		t.Errorf("missing supertype for %q: %v", artifact.Name, artifact.SupertypeNames)
+	}
+	if artifact.ColorString() != "WB" || artifact.ColorIdentityString() != "WB" {
+		t.Errorf("wrong colors. want WB/WB, got %s/%s", artifact.ColorString(), artifact.ColorIdentityString())
+	}
+}
+
+func TestLibraryAbilities(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 100, "set": "M20", "rarity": 2, "castingcost": "o1oU",
+		 "abilities": [{"abilityId": 1001, "textId": 301}, {"abilityId": 1002, "textId": 302}]}
+	]`
+	var textsJSON = `[
+		{ "isoCode": "en-US", "keys" : [
+			{"id": 100, "text": "Winged Words"},
+			{"id": 300, "text": "Flying"},
+			{"id": 301, "text": "Flying"},
+			{"id": 302, "text": "When CARDNAME enters the battlefield, draw a card."}
+		]}
+	]`
+	var abilitiesJSON = `[
+		{"id": 8, "text": 300},
+		{"id": 1001, "text": 301, "baseId": 8},
+		{"id": 1002, "text": 302}
+	]`
+
+	res := ResourceFiles{
+		Cards:     strings.NewReader(cardsJSON),
+		Texts:     strings.NewReader(textsJSON),
+		Abilities: strings.NewReader(abilitiesJSON),
+	}
+	db, err := NewLibraryFromResources(res, "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	c := db.GetCardByID(1)
+	want := "Flying\nWhen Winged Words enters the battlefield, draw a card."
+	if c.RulesText != want {
+		t.Errorf("wrong rules text. want %q, got %q", want, c.RulesText)
+	}
+	if len(c.Rules) != 2 {
+		t.Fatalf("wrong number of abilities. want 2, got %d", len(c.Rules))
+	}
+	if c.Rules[0].KeywordID != 8 || c.Rules[0].Keyword != "Flying" {
+		t.Errorf("wrong keyword. want 8 (Flying), got %d (%s)", c.Rules[0].KeywordID, c.Rules[0].Keyword)
+	}
+	if !c.HasKeyword("flying") || !c.HasKeywordID(8) || c.HasKeyword("Trample") || c.HasKeywordID(0) {
+		t.Errorf("wrong keywords for %q: %+v", c.Name, c.Rules)
+	}
+}
+
+func TestLibraryLanguages(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 1, "set": "M19", "rarity": 2, "castingcost": "oG"},
+		{"grpid": 2, "titleId": 2, "set": "M19", "rarity": 2, "castingcost": "oR"}
+	]`
+
+	db, err := NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(testTextsJSON), "ES")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	if db.Language() != "ES" {
+		t.Errorf("wrong language. want ES, got %q", db.Language())
+	}
+	if langs := db.Languages(); len(langs) != 2 {
+		t.Errorf("wrong languages. want [EN ES], got %v", langs)
+	}
+
+	c := db.GetCardByID(1)
+	if c.Name != "PRUEBA1" {
+		t.Errorf("wrong name. want %q, got %q", "PRUEBA1", c.Name)
+	}
+	if name := db.LocalizedName(c, "EN"); name != "TEST1" {
+		t.Errorf("wrong localized name. want %q, got %q", "TEST1", name)
+	}
+	if name := db.LocalizedName(c, "JP"); name != "PRUEBA1" {
+		t.Errorf("wrong fallback name. want %q, got %q", "PRUEBA1", name)
+	}
+
+	for _, name := range []string{"PRUEBA2", "TEST2"} {
+		cs := db.GetCard(name)
+		if len(cs) != 1 || cs[0].ID != 2 {
+			t.Errorf("GetCard(%q) failed. want card 2, got %v", name, cs)
+		}
+	}
+
+	if _, err := NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(testTextsJSON), "JP"); err == nil {
+		t.Errorf("NewLibrary with an unknown language should fail")
+	}
+}
+
+func TestLibraryNormalizedNames(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 100, "set": "M19", "rarity": 2, "castingcost": "oG"},
+		{"grpid": 2, "titleId": 101, "set": "ME4", "rarity": 4, "castingcost": "oUoB"},
+		{"grpid": 3, "titleId": 102, "set": "GRN", "rarity": 4, "castingcost": "o1oR"},
+		{"grpid": 4, "titleId": 103, "set": "M19", "rarity": 3, "castingcost": "o1oG"}
+	]`
+	var textsJSON = `[
+		{ "isoCode": "en-US", "keys" : [
+			{"id": 100, "text": "Llanowar Elves"},
+			{"id": 101, "text": "Lim-Dûl's Vault"},
+			{"id": 102, "text": "Fire // Ice"},
+			{"id": 103, "text": "Llanowar Elite"}
+		]}
+	]`
+
+	db, err := NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(textsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	for name, id := range map[string]uint64{
+		"llanowar elves":  1,
+		"LLANOWAR  ELVES": 1,
+		"Lim-Dul's Vault": 2,
+		"lim dul's vault": 2,
+		"Fire":            3,
+		"ice":             3,
+		"Fire//Ice":       3,
+	} {
+		cs := db.GetCard(name)
+		if len(cs) != 1 || cs[0].ID != id {
+			t.Errorf("GetCard(%q) failed. want card %d, got %v", name, id, cs)
+		}
+	}
+
+	got := db.SuggestNames("Llanowar Elfs", 2)
+	want := []string{"Llanowar Elves", "Llanowar Elite"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong suggestions. want %q, got %q", want, got)
+	}
+	if got := db.SuggestNames("llanow", 5); len(got) != 2 {
+		t.Errorf("wrong prefix suggestions. want 2, got %q", got)
+	}
+	if got := db.SuggestNames("Counterspell", 5); len(got) != 0 {
+		t.Errorf("wrong suggestions. want none, got %q", got)
+	}
+}
+
+func TestSecondaryIndexes(t *testing.T) {
+	db, err := NewLibrary(strings.NewReader(queryCardsJSON), strings.NewReader(queryTextsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	if c := db.GetCardBySetNumber("thb", "51"); c == nil || c.ID != 2 {
+		t.Errorf("GetCardBySetNumber(thb, 51) = %v, want card 2", c)
+	}
+	if c := db.GetCardBySetNumber("THB", "314"); c != nil {
+		t.Errorf("GetCardBySetNumber(THB, 314) = %v, want nil", c)
+	}
+	if cs := db.CardsInSet("THB"); len(cs) != 3 {
+		t.Errorf("CardsInSet(THB) returned %d cards, want 3", len(cs))
+	}
+	if cs := db.GetCardsByTitleID(100); len(cs) != 1 || cs[0].ID != 1 {
+		t.Errorf("GetCardsByTitleID(100) = %v, want card 1", cs)
+	}
+}
+
+func TestLibraryFaces(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 100, "set": "ELD", "CollectorNumber": "115", "rarity": 4, "isCollectible": true,
+		 "linkedFaceType": 8, "linkedFaces": [2]},
+		{"grpid": 2, "titleId": 101, "set": "ELD", "CollectorNumber": "115", "rarity": 4, "linkedFaceType": 7, "linkedFaces": [1]},
+		{"grpid": 3, "titleId": 102, "set": "GRN", "CollectorNumber": "224", "rarity": 4, "isCollectible": true,
+		 "linkedFaceType": 5, "linkedFaces": [4, 5]},
+		{"grpid": 4, "titleId": 103, "set": "GRN", "CollectorNumber": "224", "rarity": 4, "linkedFaceType": 6, "linkedFaces": [3]},
+		{"grpid": 5, "titleId": 104, "set": "GRN", "CollectorNumber": "224", "rarity": 4, "linkedFaceType": 6, "linkedFaces": [3]}
+	]`
+	var textsJSON = `[
+		{ "isoCode": "en-US", "keys" : [
+			{"id": 100, "text": "Bonecrusher Giant"},
+			{"id": 101, "text": "Stomp"},
+			{"id": 102, "text": "Expansion // Explosion"},
+			{"id": 103, "text": "Expansion"},
+			{"id": 104, "text": "Explosion"}
+		]}
+	]`
+
+	db, err := NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(textsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	for name, id := range map[string]uint64{
+		"Bonecrusher Giant":          1,
+		"Stomp":                      1,
+		"Bonecrusher Giant // Stomp": 1,
+		"Expansion // Explosion":     3,
+		"Explosion":                  3,
+	} {
+		cs := db.GetCard(name)
+		if len(cs) != 1 || cs[0].ID != id {
+			t.Errorf("GetCard(%q) failed. want card %d, got %v", name, id, cs)
+		}
+	}
+
+	giant := db.GetCardByID(1)
+	if len(giant.Faces) != 1 || giant.Faces[0].ID != 2 {
+		t.Errorf("wrong faces for %q: %v", giant.Name, giant.Faces)
+	}
+	stomp := db.GetCardByID(2)
+	if stomp.IsPrimaryFace() || stomp.PrimaryFace() != giant || stomp.LinkType() != AdventureFace {
+		t.Errorf("wrong primary face for %q: %v", stomp.Name, stomp.PrimaryFace())
+	}
+	if c := db.GetCardBySetNumber("GRN", "224"); c == nil || c.ID != 3 {
+		t.Errorf("GetCardBySetNumber(GRN, 224) = %v, want card 3", c)
+	}
+
+	collection := CollapseFaces(db, map[uint64]uint32{1: 2, 2: 2, 4: 1, 3: 3})
+	if len(collection) != 2 || collection[1] != 2 || collection[3] != 3 {
+		t.Errorf("wrong collapsed collection: %v", collection)
+	}
+}
+
+func TestLibraryTokens(t *testing.T) {
+	var cardsJSON = `[
+		{"grpid": 1, "titleId": 100, "set": "ELD", "CollectorNumber": "83", "rarity": 3, "isCollectible": true,
+		 "linkedTokens": [10]},
+		{"grpid": 2, "titleId": 101, "set": "ELD", "CollectorNumber": "159", "rarity": 2, "isCollectible": true,
+		 "linkedFaceType": 8, "linkedFaces": [3]},
+		{"grpid": 3, "titleId": 102, "set": "ELD", "CollectorNumber": "159", "rarity": 2, "linkedFaceType": 7,
+		 "linkedFaces": [2], "linkedTokens": [11]},
+		{"grpid": 10, "titleId": 110, "set": "ELD", "CollectorNumber": "T1", "rarity": 0, "isToken": true},
+		{"grpid": 11, "titleId": 110, "set": "THB", "CollectorNumber": "T2", "rarity": 0, "isToken": true}
+	]`
+	var textsJSON = `[
+		{ "isoCode": "en-US", "keys" : [
+			{"id": 100, "text": "Bake into a Pie"},
+			{"id": 101, "text": "Giant Opportunity"},
+			{"id": 102, "text": "Giant Opportunity Adventure"},
+			{"id": 110, "text": "Food"}
+		]}
+	]`
+
+	db, err := NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(textsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	pie := db.GetCardByID(1)
+	if len(pie.Tokens) != 1 || pie.Tokens[0].ID != 10 {
+		t.Errorf("wrong tokens for %q: %v", pie.Name, pie.Tokens)
+	}
+	giant := db.GetCardByID(2)
+	if len(giant.Tokens) != 0 {
+		t.Errorf("wrong tokens for %q: %v", giant.Name, giant.Tokens)
+	}
+	if ts := giant.AllTokens(); len(ts) != 1 || ts[0].ID != 11 {
+		t.Errorf("wrong tokens for all the faces of %q: %v", giant.Name, ts)
+	}
+
+	if cs := db.TokenCreators(11); len(cs) != 1 || cs[0] != giant {
+		t.Errorf("TokenCreators(11) failed. want card 2, got %v", cs)
+	}
+	var ids []uint64
+	for _, c := range db.CardsCreatingToken("food") {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("CardsCreatingToken(food) failed. want cards 1 and 2, got %v", ids)
+	}
+	if cs := db.CardsCreatingToken("Treasure"); len(cs) != 0 {
+		t.Errorf("CardsCreatingToken(Treasure) failed. want no cards, got %v", cs)
+	}
+}
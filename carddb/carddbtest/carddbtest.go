@@ -0,0 +1,404 @@
+// Package carddbtest builds small card databases for tests. The cards are described with a fluent
+// builder, which can create the CardDB in memory or write the resource files the game would have in
+// its Data folder:
+//
+//	b := carddbtest.New()
+//	b.AddCard("Llanowar Elves").Set("M19", "314").Cost("{G}").Types("Creature — Elf Druid").Stats("1", "1")
+//	b.AddCard("Shock").Rarity(carddb.CommonRarity).Cost("{R}").Types("Instant").Rules("Shock deals 2 damage to any target.")
+//	db, err := b.Build()
+package carddbtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+)
+
+// DefaultLanguage is the language of the names given to AddCard.
+const DefaultLanguage = "en-US"
+
+// DefaultSet is the set of the cards that don't call Set.
+const DefaultSet = "TST"
+
+// supertypeNames are the words of a type line that are supertypes.
+var supertypeNames = map[string]bool{
+	"Basic":     true,
+	"Legendary": true,
+	"Ongoing":   true,
+	"Snow":      true,
+	"World":     true,
+}
+
+// Builder describes a card database.
+type Builder struct {
+	cards []*CardBuilder
+	// texts are all the texts in the database in DefaultLanguage, indexed by text ID - firstTextID.
+	texts []string
+	// translations has the texts in other languages, by language and text ID.
+	translations map[string]map[uint64]string
+	// enums has the value of each type name, by enum name.
+	enums     map[string]map[string]uint64
+	abilities []abilityJSON
+	err       error
+}
+
+// First IDs for each kind of object, so they are easy to tell apart when debugging.
+const (
+	firstCardID    = 1
+	firstTextID    = 1000
+	firstAbilityID = 10000
+)
+
+// The JSON representation of the resource files, as read by carddb.
+type abilityJSON struct {
+	ID     uint64 `json:"id"`
+	TextID uint64 `json:"text"`
+}
+
+type enumJSON struct {
+	Name   string          `json:"name"`
+	Values []enumValueJSON `json:"values"`
+}
+
+type enumValueJSON struct {
+	ID     uint64 `json:"id"`
+	TextID uint64 `json:"text"`
+}
+
+type textJSON struct {
+	ID   uint64 `json:"id"`
+	Text string `json:"text"`
+}
+
+type langJSON struct {
+	IsoCode string     `json:"isoCode"`
+	Keys    []textJSON `json:"keys"`
+}
+
+// New returns an empty Builder.
+func New() *Builder {
+	return &Builder{
+		translations: make(map[string]map[uint64]string),
+		enums:        make(map[string]map[string]uint64),
+	}
+}
+
+// text returns the ID of the given text, adding it to the database if needed.
+func (b *Builder) text(s string) uint64 {
+	for i, t := range b.texts {
+		if t == s {
+			return uint64(firstTextID + i)
+		}
+	}
+	b.texts = append(b.texts, s)
+	return uint64(firstTextID + len(b.texts) - 1)
+}
+
+// enumValue returns the value of name in enum, adding it to the database if needed.
+func (b *Builder) enumValue(enum string, name string) uint64 {
+	values, ok := b.enums[enum]
+	if !ok {
+		values = make(map[string]uint64)
+		b.enums[enum] = values
+	}
+	if v, ok := values[name]; ok {
+		return v
+	}
+	v := uint64(len(values) + 1)
+	values[name] = v
+	return v
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// CardBuilder describes a card. Its methods return the CardBuilder, so they can be chained.
+type CardBuilder struct {
+	b         *Builder
+	card      carddb.CardJSON
+	colorsSet bool
+	// The linked cards are resolved when building, so their IDs can change until then.
+	tokens []*CardBuilder
+	faces  []*CardBuilder
+}
+
+// AddCard adds a card with the given name. By default, the card is a collectible common in DefaultSet,
+// with the next free collector number.
+func (b *Builder) AddCard(name string) *CardBuilder {
+	cb := &CardBuilder{b: b}
+	cb.card.ID = uint64(firstCardID + len(b.cards))
+	cb.card.TitleID = b.text(name)
+	cb.card.Set = DefaultSet
+	cb.card.CollectorNumber = strconv.Itoa(len(b.cards) + 1)
+	cb.card.Rarity = carddb.CommonRarity
+	cb.card.IsCollectible = true
+	cb.card.IsCraftable = true
+	b.cards = append(b.cards, cb)
+	return cb
+}
+
+// ID returns the grpid of the card.
+func (cb *CardBuilder) ID() uint64 {
+	return cb.card.ID
+}
+
+// GrpID sets the grpid of the card.
+func (cb *CardBuilder) GrpID(id uint64) *CardBuilder {
+	cb.card.ID = id
+	return cb
+}
+
+// Set sets the set code and collector number of the card.
+func (cb *CardBuilder) Set(set string, collectorNumber string) *CardBuilder {
+	cb.card.Set = set
+	cb.card.CollectorNumber = collectorNumber
+	return cb
+}
+
+// Rarity sets the rarity of the card. Basic lands and tokens are not craftable.
+func (cb *CardBuilder) Rarity(rarity uint64) *CardBuilder {
+	cb.card.Rarity = rarity
+	cb.card.IsCraftable = cb.card.IsCollectible && rarity > carddb.BasicLandRarity
+	return cb
+}
+
+// Cost sets the mana cost of the card, in any of the notations accepted by carddb.ParseManaCost.
+// Unless Colors is called, the colors of the card are the colors in the cost.
+func (cb *CardBuilder) Cost(cost string) *CardBuilder {
+	mc, err := carddb.ParseManaCost(cost)
+	if err != nil {
+		cb.b.setErr(err)
+		return cb
+	}
+	cb.card.CastingCost = mc.ArenaString()
+	cb.card.Cmc = mc.ManaValue()
+	if !cb.colorsSet {
+		cb.card.Colors = nil
+		for _, c := range mc.Colors() {
+			cb.card.Colors = append(cb.card.Colors, uint64(c))
+		}
+		cb.card.ColorIdentity = cb.card.Colors
+	}
+	return cb
+}
+
+// Colors sets the colors and color identity of the card.
+func (cb *CardBuilder) Colors(colors ...carddb.Color) *CardBuilder {
+	cb.colorsSet = true
+	cb.card.Colors = nil
+	for _, c := range colors {
+		cb.card.Colors = append(cb.card.Colors, uint64(c))
+	}
+	cb.card.ColorIdentity = cb.card.Colors
+	return cb
+}
+
+// Types sets the types of the card from a type line, like "Legendary Creature — Elf Druid".
+func (cb *CardBuilder) Types(typeLine string) *CardBuilder {
+	cb.card.Supertypes, cb.card.Types, cb.card.Subtypes = nil, nil, nil
+	types, subtypes := typeLine, ""
+	for _, sep := range []string{"—", " - "} {
+		if i := strings.Index(typeLine, sep); i >= 0 {
+			types, subtypes = typeLine[:i], typeLine[i+len(sep):]
+			break
+		}
+	}
+	for _, t := range strings.Fields(types) {
+		if supertypeNames[t] {
+			cb.card.Supertypes = append(cb.card.Supertypes, cb.b.enumValue("SuperType", t))
+			continue
+		}
+		cb.card.Types = append(cb.card.Types, cb.b.enumValue("CardType", t))
+	}
+	for _, t := range strings.Fields(subtypes) {
+		cb.card.Subtypes = append(cb.card.Subtypes, cb.b.enumValue("SubType", t))
+	}
+	return cb
+}
+
+// Stats sets the power and toughness of the card.
+func (cb *CardBuilder) Stats(power string, toughness string) *CardBuilder {
+	cb.card.Power = carddb.CardStat(power)
+	cb.card.Toughness = carddb.CardStat(toughness)
+	return cb
+}
+
+// Rules adds an ability to the card for each of the given texts.
+func (cb *CardBuilder) Rules(texts ...string) *CardBuilder {
+	for _, text := range texts {
+		a := abilityJSON{ID: uint64(firstAbilityID + len(cb.b.abilities)), TextID: cb.b.text(text)}
+		cb.b.abilities = append(cb.b.abilities, a)
+		cb.card.Abilities = append(cb.card.Abilities, carddb.CardAbilityRef{AbilityID: a.ID, TextID: a.TextID})
+	}
+	return cb
+}
+
+// Token makes the card a token.
+func (cb *CardBuilder) Token() *CardBuilder {
+	cb.card.IsToken = true
+	cb.card.IsCollectible = false
+	cb.card.IsCraftable = false
+	cb.card.Rarity = carddb.TokenRarity
+	return cb
+}
+
+// NotCollectible makes the card not collectible, like the cards that only exist in the game.
+func (cb *CardBuilder) NotCollectible() *CardBuilder {
+	cb.card.IsCollectible = false
+	cb.card.IsCraftable = false
+	return cb
+}
+
+// CreatesToken links the card to a token it creates.
+func (cb *CardBuilder) CreatesToken(token *CardBuilder) *CardBuilder {
+	cb.tokens = append(cb.tokens, token)
+	return cb
+}
+
+// Face links the card with another face of it. faceType is the role of this card, and otherType the
+// role of the other face, like carddb.AdventurerFace and carddb.AdventureFace. The other face is made
+// not collectible when it's a secondary face.
+func (cb *CardBuilder) Face(other *CardBuilder, faceType carddb.LinkedFaceType, otherType carddb.LinkedFaceType) *CardBuilder {
+	cb.card.LinkedFaceType = uint64(faceType)
+	other.card.LinkedFaceType = uint64(otherType)
+	cb.faces = append(cb.faces, other)
+	other.faces = append(other.faces, cb)
+	if otherType.IsSecondary() {
+		other.NotCollectible()
+	}
+	return cb
+}
+
+// Localized sets the name of the card in another language, like "es-ES".
+func (cb *CardBuilder) Localized(lang string, name string) *CardBuilder {
+	texts, ok := cb.b.translations[lang]
+	if !ok {
+		texts = make(map[uint64]string)
+		cb.b.translations[lang] = texts
+	}
+	texts[cb.card.TitleID] = name
+	return cb
+}
+
+// files returns the contents of the resource files.
+func (b *Builder) files() (cards, texts, enums, abilities []byte, err error) {
+	if b.err != nil {
+		return nil, nil, nil, nil, b.err
+	}
+
+	cs := []carddb.CardJSON{}
+	for _, cb := range b.cards {
+		c := cb.card
+		c.LinkedTokens, c.LinkedFaces = nil, nil
+		for _, t := range cb.tokens {
+			c.LinkedTokens = append(c.LinkedTokens, t.card.ID)
+		}
+		for _, f := range cb.faces {
+			c.LinkedFaces = append(c.LinkedFaces, f.card.ID)
+		}
+		cs = append(cs, c)
+	}
+
+	// The output is sorted, so the hashes of the files don't change between runs.
+	es := []enumJSON{}
+	for _, name := range []string{"CardType", "SubType", "SuperType"} {
+		e := enumJSON{Name: name, Values: []enumValueJSON{}}
+		for t, v := range b.enums[name] {
+			e.Values = append(e.Values, enumValueJSON{ID: v, TextID: b.text(t)})
+		}
+		sort.Slice(e.Values, func(i, j int) bool { return e.Values[i].ID < e.Values[j].ID })
+		es = append(es, e)
+	}
+
+	// The texts have to be added last, as the enums add their names.
+	var translated []string
+	for lang := range b.translations {
+		translated = append(translated, lang)
+	}
+	sort.Strings(translated)
+	langs := []langJSON{{IsoCode: DefaultLanguage}}
+	for _, lang := range translated {
+		langs = append(langs, langJSON{IsoCode: lang})
+	}
+	for i := range langs {
+		for j, t := range b.texts {
+			id := uint64(firstTextID + j)
+			if tr, ok := b.translations[langs[i].IsoCode][id]; ok {
+				t = tr
+			}
+			langs[i].Keys = append(langs[i].Keys, textJSON{ID: id, Text: t})
+		}
+	}
+
+	as := append([]abilityJSON{}, b.abilities...)
+	for _, f := range []struct {
+		v   interface{}
+		out *[]byte
+	}{{cs, &cards}, {langs, &texts}, {es, &enums}, {as, &abilities}} {
+		data, err := json.Marshal(f.v)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to encode JSON: %v", err)
+		}
+		*f.out = data
+	}
+	return cards, texts, enums, abilities, nil
+}
+
+// Build creates the card database, with the names in DefaultLanguage.
+func (b *Builder) Build() (carddb.CardDB, error) {
+	return b.BuildLocalized(DefaultLanguage)
+}
+
+// BuildLocalized creates the card database, with the names in lang.
+func (b *Builder) BuildLocalized(lang string) (carddb.CardDB, error) {
+	cards, texts, enums, abilities, err := b.files()
+	if err != nil {
+		return nil, err
+	}
+	return carddb.NewLibraryFromResources(carddb.ResourceFiles{
+		Cards:     bytes.NewReader(cards),
+		Texts:     bytes.NewReader(texts),
+		Enums:     bytes.NewReader(enums),
+		Abilities: bytes.NewReader(abilities),
+	}, lang)
+}
+
+// WriteFiles writes the resource files to dir, which can then be used as the Data folder of the game.
+// Like in the game, the names of the files have the hash of their contents.
+func (b *Builder) WriteFiles(dir string) (carddb.ResourcePaths, error) {
+	var paths carddb.ResourcePaths
+	cards, texts, enums, abilities, err := b.files()
+	if err != nil {
+		return paths, err
+	}
+	for _, f := range []struct {
+		prefix string
+		data   []byte
+		path   *string
+	}{
+		{"data_cards_", cards, &paths.Cards},
+		{"data_loc_", texts, &paths.Texts},
+		{"data_enums_", enums, &paths.Enums},
+		{"data_abilities_", abilities, &paths.Abilities},
+	} {
+		hash := sha256.Sum256(f.data)
+		path := filepath.Join(dir, f.prefix+hex.EncodeToString(hash[:16])+".mtga")
+		if err := ioutil.WriteFile(path, f.data, 0644); err != nil {
+			return paths, fmt.Errorf("failed to write resource file: %v", err)
+		}
+		*f.path = path
+	}
+	return paths, nil
+}
@@ -0,0 +1,78 @@
+package carddbtest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+)
+
+func testBuilder() *Builder {
+	b := New()
+	b.AddCard("Llanowar Elves").Set("M19", "314").Cost("{G}").Types("Creature — Elf Druid").Stats("1", "1").
+		Rules("{T}: Add {G}.").Localized("es-ES", "Elfos de Llanowar")
+	food := b.AddCard("Food").Set("ELD", "T15").Types("Artifact — Food").Token()
+	giant := b.AddCard("Giant Opportunity").Set("ELD", "159").Rarity(carddb.UncommonRarity).Cost("2G").
+		Types("Sorcery").CreatesToken(food)
+	adventure := b.AddCard("Stomp").Set("ELD", "159").Cost("1R").Types("Instant — Adventure")
+	giant.Face(adventure, carddb.AdventurerFace, carddb.AdventureFace)
+	b.AddCard("Forest").Set("M19", "277").Rarity(carddb.BasicLandRarity).Types("Basic Land — Forest")
+	return b
+}
+
+func TestBuild(t *testing.T) {
+	db, err := testBuilder().Build()
+	if err != nil {
+		t.Fatalf("failed to build library: %v", err)
+	}
+
+	cs := db.GetCard("Llanowar Elves")
+	if len(cs) != 1 {
+		t.Fatalf("GetCard(Llanowar Elves) failed. want 1 card, got %v", cs)
+	}
+	elves := cs[0]
+	if elves.TypeLine != "Creature — Elf Druid" || elves.ManaCost.String() != "{G}" || elves.ColorString() != "G" ||
+		elves.Power != "1" || elves.RulesText != "{T}: Add {G}." || !elves.IsCraftable {
+		t.Errorf("wrong Llanowar Elves: %+v", *elves)
+	}
+
+	giant := db.GetCardBySetNumber("ELD", "159")
+	if giant == nil || giant.Name != "Giant Opportunity" || giant.Cmc != 3 || giant.FullName() != "Giant Opportunity // Stomp" {
+		t.Errorf("wrong Giant Opportunity: %+v", giant)
+	}
+	if cs := db.CardsCreatingToken("Food"); len(cs) != 1 || cs[0] != giant {
+		t.Errorf("CardsCreatingToken(Food) failed. want Giant Opportunity, got %v", cs)
+	}
+
+	forest := db.GetCardBySetNumber("M19", "277")
+	if forest == nil || forest.TypeLine != "Basic Land — Forest" || forest.IsCraftable {
+		t.Errorf("wrong Forest: %+v", forest)
+	}
+
+	if _, err := New().AddCard("Bad").Cost("{Q}").b.Build(); err == nil {
+		t.Errorf("Build with an invalid cost should have failed")
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carddbtest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := testBuilder().WriteFiles(dir); err != nil {
+		t.Fatalf("failed to write files: %v", err)
+	}
+	db, err := carddb.CreateLocalizedLibrary(dir, "es-ES")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	if c := db.GetCardByID(1); c == nil || c.Name != "Elfos de Llanowar" {
+		t.Errorf("wrong card 1: %v", c)
+	}
+	if c := db.GetCardBySetNumber("M19", "277"); c == nil || c.Name != "Forest" {
+		t.Errorf("untranslated card should keep its english name, got %v", c)
+	}
+}
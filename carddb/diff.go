@@ -0,0 +1,158 @@
+package carddb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diff has the differences between two card databases, typically from two versions of the game.
+type Diff struct {
+	// Added are the cards that are only in the new database, sorted by ID.
+	Added []*Card
+	// Removed are the cards that are only in the old database, sorted by ID.
+	Removed []*Card
+	// Changed are the cards present in both databases whose fields changed, sorted by ID. The new
+	// name of a renamed title is only in Renamed.
+	Changed []CardChange
+	// Renamed are the titles whose name changed, sorted by title ID.
+	Renamed []TitleRename
+	// NewPrintings are the added cards whose title was already in the old database. They are
+	// also in Added.
+	NewPrintings []*Card
+	// Rebalanced are the added cards that are an Alchemy rebalance of a card in the old database
+	// (the "A-" version of the card). They are also in Added.
+	Rebalanced []*Card
+}
+
+// CardChange has the fields that changed for a card.
+type CardChange struct {
+	ID     uint64        `json:"id"`
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a field of a card that has a different value in each database.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// TitleRename is a title whose name changed.
+type TitleRename struct {
+	TitleID uint64 `json:"titleId"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// rebalancedPrefix is the prefix of the names of Alchemy rebalanced cards.
+const rebalancedPrefix = "A-"
+
+// diffFields are the fields compared by DiffLibraries.
+var diffFields = []struct {
+	name  string
+	value func(c *Card) string
+}{
+	{"name", func(c *Card) string { return c.Name }},
+	{"titleId", func(c *Card) string { return fmt.Sprint(c.TitleID) }},
+	{"set", func(c *Card) string { return c.Set }},
+	{"collectorNumber", func(c *Card) string { return c.CollectorNumber }},
+	{"rarity", func(c *Card) string { return fmt.Sprint(c.Rarity) }},
+	{"castingCost", func(c *Card) string { return c.CastingCost }},
+	{"cmc", func(c *Card) string { return fmt.Sprint(c.Cmc) }},
+	{"colors", func(c *Card) string { return c.ColorString() }},
+	{"colorIdentity", func(c *Card) string { return c.ColorIdentityString() }},
+	{"typeLine", func(c *Card) string { return c.TypeLine }},
+	{"power", func(c *Card) string { return string(c.Power) }},
+	{"toughness", func(c *Card) string { return string(c.Toughness) }},
+	{"rulesText", func(c *Card) string { return c.RulesText }},
+	{"artId", func(c *Card) string { return fmt.Sprint(c.ArtID) }},
+	{"isToken", func(c *Card) string { return fmt.Sprint(c.IsToken) }},
+	{"isCollectible", func(c *Card) string { return fmt.Sprint(c.IsCollectible) }},
+	{"isCraftable", func(c *Card) string { return fmt.Sprint(c.IsCraftable) }},
+}
+
+// DiffLibraries compares the cards in oldDB and newDB. Cards are matched by ID. Both databases should
+// use the same language, otherwise every card shows up as renamed.
+func DiffLibraries(oldDB, newDB CardDB) *Diff {
+	d := &Diff{}
+	oldTitles := make(map[uint64]string)
+	oldNames := make(map[string]bool)
+	oldDB.ForEach(func(c Card) {
+		if _, ok := oldTitles[c.TitleID]; !ok && c.TitleID != 0 {
+			oldTitles[c.TitleID] = c.Name
+		}
+		oldNames[c.Name] = true
+		if newDB.GetCardByID(c.ID) == nil {
+			d.Removed = append(d.Removed, oldDB.GetCardByID(c.ID))
+		}
+	})
+
+	newTitles := make(map[uint64]string)
+	// kept are the cards in both databases, as (old, new) pairs.
+	var kept [][2]*Card
+	newDB.ForEach(func(c Card) {
+		card := newDB.GetCardByID(c.ID)
+		old := oldDB.GetCardByID(c.ID)
+		if old == nil {
+			d.Added = append(d.Added, card)
+			if _, ok := oldTitles[c.TitleID]; ok {
+				d.NewPrintings = append(d.NewPrintings, card)
+			}
+			if strings.HasPrefix(c.Name, rebalancedPrefix) && oldNames[strings.TrimPrefix(c.Name, rebalancedPrefix)] {
+				d.Rebalanced = append(d.Rebalanced, card)
+			}
+		} else {
+			kept = append(kept, [2]*Card{old, card})
+		}
+		if _, ok := newTitles[c.TitleID]; !ok && c.TitleID != 0 {
+			newTitles[c.TitleID] = c.Name
+		}
+	})
+	renamed := make(map[uint64]bool)
+	for id, name := range newTitles {
+		if oldName, ok := oldTitles[id]; ok && oldName != name {
+			d.Renamed = append(d.Renamed, TitleRename{TitleID: id, Old: oldName, New: name})
+			renamed[id] = true
+		}
+	}
+
+	// The printings of a renamed title are already covered by Renamed, and their name is not
+	// reported again as a change.
+	for _, cs := range kept {
+		old, card := cs[0], cs[1]
+		var fields []FieldChange
+		for _, f := range diffCard(old, card) {
+			if f.Field == "name" && old.TitleID == card.TitleID && renamed[card.TitleID] {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		if len(fields) > 0 {
+			d.Changed = append(d.Changed, CardChange{ID: card.ID, Name: card.Name, Fields: fields})
+		}
+	}
+
+	for _, cs := range [][]*Card{d.Added, d.Removed, d.NewPrintings, d.Rebalanced} {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].ID < cs[j].ID })
+	}
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].ID < d.Changed[j].ID })
+	sort.Slice(d.Renamed, func(i, j int) bool { return d.Renamed[i].TitleID < d.Renamed[j].TitleID })
+	return d
+}
+
+func diffCard(old, new *Card) []FieldChange {
+	var fields []FieldChange
+	for _, f := range diffFields {
+		if o, n := f.value(old), f.value(new); o != n {
+			fields = append(fields, FieldChange{Field: f.name, Old: o, New: n})
+		}
+	}
+	return fields
+}
+
+// Empty returns whether there are no differences.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Renamed) == 0
+}
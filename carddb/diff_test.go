@@ -0,0 +1,75 @@
+package carddb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLibraries(t *testing.T) {
+	oldDB, err := NewLibrary(strings.NewReader(queryCardsJSON), strings.NewReader(queryTextsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create old library: %v", err)
+	}
+
+	// Card 3 is now mythic, card 4 was removed, card 5 is a new printing of Llanowar Elves, card 6 is
+	// a rebalanced Mantle of Tides, and Mantle of Tides was renamed.
+	newCards := `[
+		{"grpid": 1, "titleId": 100, "set": "M19", "CollectorNumber": "314", "rarity": 2, "cmc": 1, "castingcost": "oG", "power": 1, "toughness": 1,
+		 "colors": [5], "colorIdentity": [5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 201,
+		 "abilities": [{"abilityId": 10, "textId": 300}]},
+		{"grpid": 2, "titleId": 101, "set": "THB", "CollectorNumber": "51", "rarity": 5, "cmc": 3, "castingcost": "o1oGoU", "power": 3, "toughness": 3,
+		 "colors": [2, 5], "colorIdentity": [2, 5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 202,
+		 "abilities": [{"abilityId": 11, "textId": 301}]},
+		{"grpid": 3, "titleId": 102, "set": "THB", "CollectorNumber": "60", "rarity": 5, "cmc": 2, "castingcost": "o1oU",
+		 "colors": [2], "colorIdentity": [2], "types": [4], "cardTypeTextId": 203,
+		 "abilities": [{"abilityId": 12, "textId": 302}]},
+		{"grpid": 5, "titleId": 100, "set": "DAR", "CollectorNumber": "168", "rarity": 2, "cmc": 1, "power": 1, "toughness": 1,
+		 "colors": [5], "colorIdentity": [5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 201},
+		{"grpid": 6, "titleId": 104, "set": "Y22", "CollectorNumber": "51", "rarity": 5, "cmc": 2, "power": 3, "toughness": 3,
+		 "colors": [2, 5], "colorIdentity": [2, 5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 202}
+	]`
+	newTexts := strings.Replace(queryTextsJSON, `"Mantle of Tides"`, `"Mantle of the Tides"`, 1)
+	newTexts = strings.Replace(newTexts, `{"id": 100,`, `{"id": 104, "text": "A-Mantle of Tides"}, {"id": 100,`, 1)
+	newDB, err := NewLibrary(strings.NewReader(newCards), strings.NewReader(newTexts), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create new library: %v", err)
+	}
+
+	ids := func(cs []*Card) []uint64 {
+		var res []uint64
+		for _, c := range cs {
+			res = append(res, c.ID)
+		}
+		return res
+	}
+
+	d := DiffLibraries(oldDB, newDB)
+	if got, want := ids(d.Added), []uint64{5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Added mismatch. want %v, got %v", want, got)
+	}
+	if got, want := ids(d.Removed), []uint64{4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Removed mismatch. want %v, got %v", want, got)
+	}
+	if got, want := ids(d.NewPrintings), []uint64{5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NewPrintings mismatch. want %v, got %v", want, got)
+	}
+	if got, want := ids(d.Rebalanced), []uint64{6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rebalanced mismatch. want %v, got %v", want, got)
+	}
+	wantRenamed := []TitleRename{{TitleID: 101, Old: "Mantle of Tides", New: "Mantle of the Tides"}}
+	if !reflect.DeepEqual(d.Renamed, wantRenamed) {
+		t.Errorf("Renamed mismatch. want %v, got %v", wantRenamed, d.Renamed)
+	}
+	// Mantle of Tides is only in Renamed, and not in Changed with a new name.
+	wantChanged := []CardChange{
+		{ID: 3, Name: "Thirst for Meaning", Fields: []FieldChange{{"rarity", "4", "5"}}},
+	}
+	if !reflect.DeepEqual(d.Changed, wantChanged) {
+		t.Errorf("Changed mismatch. want %+v, got %+v", wantChanged, d.Changed)
+	}
+
+	if d := DiffLibraries(oldDB, oldDB); !d.Empty() {
+		t.Errorf("diff of a library with itself is not empty: %+v", d)
+	}
+}
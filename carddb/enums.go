@@ -0,0 +1,180 @@
+package carddb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Names of the enums in the data_enums resource file that carddb knows how to use.
+const (
+	cardTypeEnum  = "CardType"
+	subtypeEnum   = "SubType"
+	supertypeEnum = "SuperType"
+)
+
+// Color is a Magic color, as it is stored in the colors and colorIdentity fields of a card.
+type Color uint64
+
+const (
+	// White is the color constant for white (W).
+	White Color = 1
+	// Blue is the color constant for blue (U).
+	Blue Color = 2
+	// Black is the color constant for black (B).
+	Black Color = 3
+	// Red is the color constant for red (R).
+	Red Color = 4
+	// Green is the color constant for green (G).
+	Green Color = 5
+)
+
+// AllColors has all the colors in WUBRG order.
+var AllColors = []Color{White, Blue, Black, Red, Green}
+
+var colorLetters = map[Color]string{
+	White: "W",
+	Blue:  "U",
+	Black: "B",
+	Red:   "R",
+	Green: "G",
+}
+
+// String returns the one-letter abbreviation of the color (one of WUBRG).
+func (c Color) String() string {
+	if l, ok := colorLetters[c]; ok {
+		return l
+	}
+	return fmt.Sprintf("Color(%d)", uint64(c))
+}
+
+// ParseColor converts a one-letter abbreviation (case insensitive) into a Color.
+func ParseColor(s string) (Color, bool) {
+	for c, l := range colorLetters {
+		if strings.EqualFold(l, s) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+type enumJSON struct {
+	Name   string          `json:"name"`
+	Values []enumValueJSON `json:"values"`
+}
+
+type enumValueJSON struct {
+	ID     uint64 `json:"id"`
+	TextID uint64 `json:"text"`
+}
+
+// enums maps an enum name to its values. Each value maps to the text ID with its localized name.
+type enums map[string]map[uint64]uint64
+
+func parseEnumsFile(enumsFile io.Reader) (enums, error) {
+	decoder := json.NewDecoder(enumsFile)
+	var es []enumJSON
+	if err := decoder.Decode(&es); err != nil {
+		return nil, fmt.Errorf("Failed to decode JSON: %v", err)
+	}
+
+	m := make(enums)
+	for _, e := range es {
+		values := make(map[uint64]uint64)
+		for _, v := range e.Values {
+			values[v.ID] = v.TextID
+		}
+		m[e.Name] = values
+	}
+	return m, nil
+}
+
+// names resolves the given values of enum into their localized names. Unknown values are skipped.
+func (e enums) names(enum string, values []uint64, texts map[uint64]string) []string {
+	var res []string
+	for _, v := range values {
+		textID, ok := e[enum][v]
+		if !ok {
+			continue
+		}
+		if name, ok := texts[textID]; ok {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// typeLine builds the type line for card. It uses the localized type line texts from the card if they
+// are available, and falls back to the type names otherwise.
+func typeLine(card *Card, texts map[uint64]string) string {
+	types, ok := texts[card.CardTypeTextID]
+	if !ok || card.CardTypeTextID == 0 {
+		types = strings.Join(append(append([]string{}, card.SupertypeNames...), card.TypeNames...), " ")
+	}
+	subtypes, ok := texts[card.SubtypeTextID]
+	if !ok || card.SubtypeTextID == 0 {
+		subtypes = strings.Join(card.SubtypeNames, " ")
+	}
+	if subtypes == "" {
+		return types
+	}
+	return types + " — " + subtypes
+}
+
+func colorString(colors []uint64) string {
+	var sb strings.Builder
+	for _, c := range AllColors {
+		for _, v := range colors {
+			if Color(v) == c {
+				sb.WriteString(c.String())
+				break
+			}
+		}
+	}
+	return sb.String()
+}
+
+func hasColor(colors []uint64, c Color) bool {
+	for _, v := range colors {
+		if Color(v) == c {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(ls []string, s string) bool {
+	for _, v := range ls {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ColorString returns the colors of the card in WUBRG order, e.g. "UG". Colorless cards return "".
+func (c *Card) ColorString() string {
+	return colorString(c.Colors)
+}
+
+// ColorIdentityString returns the color identity of the card in WUBRG order, e.g. "UG".
+func (c *Card) ColorIdentityString() string {
+	return colorString(c.ColorIdentity)
+}
+
+// HasColor returns whether the card is of the given color.
+func (c *Card) HasColor(color Color) bool {
+	return hasColor(c.Colors, color)
+}
+
+// HasType returns whether the card has the given type or supertype (e.g. "Creature" or "Legendary").
+// The comparison is case insensitive, and uses the language the library was created with.
+func (c *Card) HasType(name string) bool {
+	return containsFold(c.TypeNames, name) || containsFold(c.SupertypeNames, name)
+}
+
+// HasSubtype returns whether the card has the given subtype (e.g. "Elf").
+func (c *Card) HasSubtype(name string) bool {
+	return containsFold(c.SubtypeNames, name)
+}
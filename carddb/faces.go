@@ -0,0 +1,137 @@
+package carddb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LinkedFaceType is the role of a card in a group of linked cards, as stored in the linkedFaceType
+// field of the cards file. The values mirror the client's LinkedFace enum.
+type LinkedFaceType uint64
+
+const (
+	// NoLinkedFace is the type of single faced cards.
+	NoLinkedFace LinkedFaceType = 0
+	// DFCBackFace is the back face of a transforming double-faced card.
+	DFCBackFace LinkedFaceType = 1
+	// DFCFrontFace is the front face of a transforming double-faced card.
+	DFCFrontFace LinkedFaceType = 2
+	// MeldedFace is the card that results from melding two cards.
+	MeldedFace LinkedFaceType = 3
+	// MeldPartFace is one of the two cards that meld together.
+	MeldPartFace LinkedFaceType = 4
+	// SplitCardFace is a whole split card, like "Fire // Ice".
+	SplitCardFace LinkedFaceType = 5
+	// SplitHalfFace is one of the halves of a split card.
+	SplitHalfFace LinkedFaceType = 6
+	// AdventureFace is the adventure of an adventurer card.
+	AdventureFace LinkedFaceType = 7
+	// AdventurerFace is a card with an adventure.
+	AdventurerFace LinkedFaceType = 8
+	// MDFCFrontFace is the front face of a modal double-faced card.
+	MDFCFrontFace LinkedFaceType = 9
+	// MDFCBackFace is the back face of a modal double-faced card.
+	MDFCBackFace LinkedFaceType = 10
+)
+
+var linkedFaceTypeNames = map[LinkedFaceType]string{
+	NoLinkedFace:   "None",
+	DFCBackFace:    "DFC Back",
+	DFCFrontFace:   "DFC Front",
+	MeldedFace:     "Melded",
+	MeldPartFace:   "Meld Part",
+	SplitCardFace:  "Split Card",
+	SplitHalfFace:  "Split Half",
+	AdventureFace:  "Adventure",
+	AdventurerFace: "Adventurer",
+	MDFCFrontFace:  "MDFC Front",
+	MDFCBackFace:   "MDFC Back",
+}
+
+func (t LinkedFaceType) String() string {
+	if name, ok := linkedFaceTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("LinkedFaceType(%d)", uint64(t))
+}
+
+// IsSecondary returns whether cards with this type are only a part of another card. Secondary faces
+// are not collected or crafted on their own, the primary face is.
+func (t LinkedFaceType) IsSecondary() bool {
+	switch t {
+	case DFCBackFace, MeldedFace, SplitHalfFace, AdventureFace, MDFCBackFace:
+		return true
+	}
+	return false
+}
+
+// linkFaces fills in the Faces of every card in cards.
+func linkFaces(cards []Card, byID map[uint64]*Card) {
+	for i := range cards {
+		card := &cards[i]
+		for _, id := range card.LinkedFaces {
+			if face, ok := byID[id]; ok && face != card {
+				card.Faces = append(card.Faces, face)
+			}
+		}
+	}
+}
+
+// LinkType returns the role of the card among its linked faces.
+func (c *Card) LinkType() LinkedFaceType {
+	return LinkedFaceType(c.LinkedFaceType)
+}
+
+// IsPrimaryFace returns whether the card is a card on its own, and not the back or a half of another card.
+func (c *Card) IsPrimaryFace() bool {
+	return !c.LinkType().IsSecondary()
+}
+
+// PrimaryFace returns the card that c is a face of. For primary faces, it returns c.
+func (c *Card) PrimaryFace() *Card {
+	if c.IsPrimaryFace() {
+		return c
+	}
+	for _, f := range c.Faces {
+		if f.IsPrimaryFace() {
+			return f
+		}
+	}
+	return c
+}
+
+// FullName returns the name of the card including the names of its other faces, like "Front // Back".
+// Split cards already have the full name as their name, and melded cards are not part of the name.
+func (c *Card) FullName() string {
+	if !c.IsPrimaryFace() || c.LinkType() == SplitCardFace || c.LinkType() == MeldPartFace {
+		return c.Name
+	}
+	names := []string{c.Name}
+	for _, f := range c.Faces {
+		if f.LinkType().IsSecondary() {
+			names = append(names, f.Name)
+		}
+	}
+	return strings.Join(names, " // ")
+}
+
+// isSecondaryPrinting returns whether c should not be the card identified by its set and collector number.
+func isSecondaryPrinting(c *Card) bool {
+	return c.IsToken || !c.IsPrimaryFace()
+}
+
+// CollapseFaces returns a copy of collection (card counts by card ID) where the counts for secondary
+// faces are moved to their primary face, so each card is counted once. When both faces of a card are
+// in collection, the highest count is used.
+func CollapseFaces(db CardDB, collection map[uint64]uint32) map[uint64]uint32 {
+	res := make(map[uint64]uint32)
+	for id, count := range collection {
+		if c := db.GetCardByID(id); c != nil {
+			id = c.PrimaryFace().ID
+		}
+		if count > res[id] {
+			res[id] = count
+		}
+	}
+	return res
+}
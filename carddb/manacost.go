@@ -0,0 +1,267 @@
+package carddb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManaSymbol is a symbol in a mana cost, like {2}, {G}, {W/U}, {G/P} or {X}.
+type ManaSymbol struct {
+	// Generic is the amount of generic mana, for symbols like {2}. It is also set for monocolored
+	// hybrid symbols like {2/W}.
+	Generic uint64
+	// Colors are the colors of the symbol. Hybrid symbols have more than one.
+	Colors []Color
+	// Colorless is set for symbols that require colorless mana, {C}.
+	Colorless bool
+	// Phyrexian is set for symbols that can be paid with 2 life, like {G/P}.
+	Phyrexian bool
+	// Variable is the name of a variable amount of mana, like "X".
+	Variable string
+	// Snow is set for the {S} symbol.
+	Snow bool
+}
+
+// IsGeneric returns whether the symbol is a plain amount of generic mana, like {2}.
+func (s ManaSymbol) IsGeneric() bool {
+	return len(s.Colors) == 0 && !s.Colorless && !s.Phyrexian && s.Variable == "" && !s.Snow
+}
+
+// IsHybrid returns whether the symbol can be paid with more than one kind of mana.
+func (s ManaSymbol) IsHybrid() bool {
+	kinds := len(s.Colors)
+	if s.Generic > 0 {
+		kinds++
+	}
+	if s.Colorless {
+		kinds++
+	}
+	return kinds > 1
+}
+
+// ManaValue returns the mana value (converted mana cost) of the symbol.
+func (s ManaSymbol) ManaValue() uint64 {
+	switch {
+	case s.Variable != "":
+		return 0
+	case s.Generic > 0 || s.IsGeneric():
+		return s.Generic
+	}
+	return 1
+}
+
+// String returns the symbol in the {G} notation used in the rules text.
+func (s ManaSymbol) String() string {
+	switch {
+	case s.Variable != "":
+		return "{" + s.Variable + "}"
+	case s.Snow:
+		return "{S}"
+	case s.IsGeneric():
+		return "{" + strconv.FormatUint(s.Generic, 10) + "}"
+	}
+	var parts []string
+	if s.Generic > 0 {
+		parts = append(parts, strconv.FormatUint(s.Generic, 10))
+	}
+	if s.Colorless {
+		parts = append(parts, "C")
+	}
+	for _, c := range s.Colors {
+		parts = append(parts, c.String())
+	}
+	if s.Phyrexian {
+		parts = append(parts, "P")
+	}
+	return "{" + strings.Join(parts, "/") + "}"
+}
+
+// ManaCost is the mana cost of a card, as a list of symbols.
+type ManaCost []ManaSymbol
+
+// ParseManaCost parses a mana cost. It accepts the Arena notation used in the casting cost of the
+// cards ("o2oGoG", "o(W/U)", "oX"), the {2}{G}{G} notation and a shorthand without delimiters ("2GG").
+// An empty string is an empty cost.
+func ParseManaCost(cost string) (ManaCost, error) {
+	var symbols []string
+	switch {
+	case cost == "":
+		return nil, nil
+	case strings.HasPrefix(cost, "o"):
+		symbols = strings.Split(cost[1:], "o")
+	case strings.HasPrefix(cost, "{"):
+		if !strings.HasSuffix(cost, "}") {
+			return nil, fmt.Errorf("invalid mana cost %q", cost)
+		}
+		for _, s := range strings.Split(cost, "}") {
+			if s == "" {
+				continue
+			}
+			if !strings.HasPrefix(s, "{") {
+				return nil, fmt.Errorf("invalid mana cost %q", cost)
+			}
+			symbols = append(symbols, s[1:])
+		}
+	default:
+		for i := 0; i < len(cost); i++ {
+			j := i + 1
+			for cost[i] >= '0' && cost[i] <= '9' && j < len(cost) && cost[j] >= '0' && cost[j] <= '9' {
+				j++
+			}
+			symbols = append(symbols, cost[i:j])
+			i = j - 1
+		}
+	}
+
+	var mc ManaCost
+	for _, s := range symbols {
+		sym, err := parseManaSymbol(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mana cost %q: %v", cost, err)
+		}
+		mc = append(mc, sym)
+	}
+	return mc, nil
+}
+
+// parseManaSymbol parses the inside of a mana symbol, like "2", "G", "W/U" or "(G/P)".
+func parseManaSymbol(s string) (ManaSymbol, error) {
+	var sym ManaSymbol
+	text := strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(s, "("), ")"))
+	if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+		sym.Generic = n
+		return sym, nil
+	}
+	switch text {
+	case "X", "Y", "Z":
+		sym.Variable = text
+		return sym, nil
+	case "S":
+		sym.Snow = true
+		return sym, nil
+	}
+
+	parts := strings.Split(text, "/")
+	if len(parts) == 1 {
+		// Arena writes some hybrid and phyrexian symbols without a separator, like "GP".
+		parts = strings.Split(text, "")
+	}
+	for _, p := range parts {
+		if n, err := strconv.ParseUint(p, 10, 64); err == nil && sym.Generic == 0 {
+			sym.Generic = n
+			continue
+		}
+		switch p {
+		case "C":
+			sym.Colorless = true
+			continue
+		case "P":
+			sym.Phyrexian = true
+			continue
+		}
+		c, ok := ParseColor(p)
+		if !ok {
+			return sym, fmt.Errorf("unknown mana symbol %q", s)
+		}
+		sym.Colors = append(sym.Colors, c)
+	}
+	if len(sym.Colors) == 0 && !sym.Colorless {
+		return sym, fmt.Errorf("unknown mana symbol %q", s)
+	}
+	return sym, nil
+}
+
+// String returns the cost in the {2}{G}{G} notation used in the rules text.
+func (mc ManaCost) String() string {
+	var b strings.Builder
+	for _, s := range mc {
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+// ArenaString returns the cost in the notation used by Arena in the casting cost of the cards, like "o2oGoG".
+func (mc ManaCost) ArenaString() string {
+	var b strings.Builder
+	for _, s := range mc {
+		sym := strings.Trim(s.String(), "{}")
+		if strings.Contains(sym, "/") {
+			sym = "(" + sym + ")"
+		}
+		b.WriteString("o" + sym)
+	}
+	return b.String()
+}
+
+// ManaValue returns the mana value (converted mana cost) of the cost. X is 0.
+func (mc ManaCost) ManaValue() uint64 {
+	var v uint64
+	for _, s := range mc {
+		v += s.ManaValue()
+	}
+	return v
+}
+
+// Pips returns the number of colored symbols of each color. Hybrid symbols count for all their colors.
+func (mc ManaCost) Pips() map[Color]int {
+	pips := make(map[Color]int)
+	for _, s := range mc {
+		for _, c := range s.Colors {
+			pips[c]++
+		}
+	}
+	return pips
+}
+
+// Colors returns the colors in the cost, in WUBRG order.
+func (mc ManaCost) Colors() []Color {
+	var colors []Color
+	for c := range mc.Pips() {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool { return colors[i] < colors[j] })
+	return colors
+}
+
+// generic returns the total amount of plain generic mana in the cost.
+func (mc ManaCost) generic() uint64 {
+	var n uint64
+	for _, s := range mc {
+		if s.IsGeneric() {
+			n += s.Generic
+		}
+	}
+	return n
+}
+
+// symbolCounts counts the symbols in the cost that are not plain generic mana.
+func (mc ManaCost) symbolCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, s := range mc {
+		if !s.IsGeneric() {
+			counts[s.String()]++
+		}
+	}
+	return counts
+}
+
+// contains returns whether mc has at least all the symbols in other, and at least as much generic mana.
+func (mc ManaCost) contains(other ManaCost) bool {
+	if mc.generic() < other.generic() {
+		return false
+	}
+	have := mc.symbolCounts()
+	for s, n := range other.symbolCounts() {
+		if have[s] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// equal returns whether mc and other have the same symbols, in any order.
+func (mc ManaCost) equal(other ManaCost) bool {
+	return mc.contains(other) && other.contains(mc)
+}
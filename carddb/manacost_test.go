@@ -0,0 +1,70 @@
+package carddb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseManaCost(t *testing.T) {
+	tests := []struct {
+		cost      string
+		want      string
+		manaValue uint64
+		pips      map[Color]int
+	}{
+		{"", "", 0, map[Color]int{}},
+		{"o0", "{0}", 0, map[Color]int{}},
+		{"o6oGoG", "{6}{G}{G}", 8, map[Color]int{Green: 2}},
+		{"oXoRoR", "{X}{R}{R}", 2, map[Color]int{Red: 2}},
+		{"o1o(W/U)o(W/U)", "{1}{W/U}{W/U}", 3, map[Color]int{White: 2, Blue: 2}},
+		{"o(G/P)oC", "{G/P}{C}", 2, map[Color]int{Green: 1}},
+		{"oGPo(2/W)", "{G/P}{2/W}", 3, map[Color]int{Green: 1, White: 1}},
+		{"o(G/U/P)", "{G/U/P}", 1, map[Color]int{Green: 1, Blue: 1}},
+		{"o1oSoS", "{1}{S}{S}", 3, map[Color]int{}},
+		{"{2}{B}{B}", "{2}{B}{B}", 4, map[Color]int{Black: 2}},
+		{"{X}{2/W}", "{X}{2/W}", 2, map[Color]int{White: 1}},
+		{"10UU", "{10}{U}{U}", 12, map[Color]int{Blue: 2}},
+	}
+
+	for _, test := range tests {
+		mc, err := ParseManaCost(test.cost)
+		if err != nil {
+			t.Errorf("ParseManaCost(%q) failed: %v", test.cost, err)
+			continue
+		}
+		if got := mc.String(); got != test.want {
+			t.Errorf("ParseManaCost(%q).String() mismatch. want %q, got %q", test.cost, test.want, got)
+		}
+		if got, err := ParseManaCost(mc.ArenaString()); err != nil || got.String() != test.want {
+			t.Errorf("ParseManaCost(%q) doesn't round trip through ArenaString: %q", test.cost, mc.ArenaString())
+		}
+		if got := mc.ManaValue(); got != test.manaValue {
+			t.Errorf("ParseManaCost(%q).ManaValue() mismatch. want %d, got %d", test.cost, test.manaValue, got)
+		}
+		if got := mc.Pips(); !reflect.DeepEqual(got, test.pips) {
+			t.Errorf("ParseManaCost(%q).Pips() mismatch. want %v, got %v", test.cost, test.pips, got)
+		}
+	}
+
+	for _, cost := range []string{"oQ", "{2}{G", "o(W/Q)", "oP"} {
+		if _, err := ParseManaCost(cost); err == nil {
+			t.Errorf("ParseManaCost(%q) should have failed", cost)
+		}
+	}
+}
+
+func TestManaSymbolHybrid(t *testing.T) {
+	mc, err := ParseManaCost("o(W/U)o(2/B)oGPoR")
+	if err != nil {
+		t.Fatalf("ParseManaCost failed: %v", err)
+	}
+	want := []bool{true, true, false, false}
+	for i, s := range mc {
+		if got := s.IsHybrid(); got != want[i] {
+			t.Errorf("%v.IsHybrid() mismatch. want %v, got %v", s, want[i], got)
+		}
+	}
+	if got, want := mc.Colors(), []Color{White, Blue, Black, Red, Green}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Colors() mismatch. want %v, got %v", want, got)
+	}
+}
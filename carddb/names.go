@@ -0,0 +1,199 @@
+package carddb
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// accentFolds maps letters with diacritics to their plain counterparts.
+var accentFolds = map[rune]string{}
+
+func init() {
+	folds := map[string]string{
+		"àáâãäåāăą":  "a",
+		"çćĉċč":      "c",
+		"ďđ":         "d",
+		"èéêëēĕėęě":  "e",
+		"ĝğġģ":       "g",
+		"ĥħ":         "h",
+		"ìíîïĩīĭįı":  "i",
+		"ĵ":          "j",
+		"ķ":          "k",
+		"ĺļľŀł":      "l",
+		"ñńņňŉ":      "n",
+		"òóôõöøōŏő":  "o",
+		"ŕŗř":        "r",
+		"śŝşš":       "s",
+		"ţťŧ":        "t",
+		"ùúûüũūŭůűų": "u",
+		"ŵ":          "w",
+		"ýÿŷ":        "y",
+		"źżž":        "z",
+		"æ":          "ae",
+		"œ":          "oe",
+		"ß":          "ss",
+		"þ":          "th",
+	}
+	for letters, base := range folds {
+		for _, r := range letters {
+			accentFolds[r] = base
+		}
+	}
+}
+
+// normalizeName folds a card name into the form used for lenient lookups: lower case, without
+// diacritics or punctuation, and with single spaces between words. "Lim-Dûl's Vault" and
+// "lim dul's vault" both become "lim duls vault".
+func normalizeName(name string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '\'' || r == '’' || r == '‘' || r == '`':
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			if f, ok := accentFolds[r]; ok {
+				sb.WriteString(f)
+			} else {
+				sb.WriteRune(r)
+			}
+		default:
+			space = true
+		}
+	}
+	return sb.String()
+}
+
+// faceNames returns the names of each face of a card with multiple faces in its name, like split
+// cards ("Fire // Ice"). It returns nil for single-faced names.
+func faceNames(name string) []string {
+	if !strings.Contains(name, "//") {
+		return nil
+	}
+	var res []string
+	for _, f := range strings.Split(name, "//") {
+		if f = strings.TrimSpace(f); f != "" {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// nameIndex indexes cards by their normalized names.
+type nameIndex struct {
+	cards map[string][]*Card
+	// display has the name to show to users for each normalized name.
+	display map[string]string
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		cards:   make(map[string][]*Card),
+		display: make(map[string]string),
+	}
+}
+
+// add indexes c under name and, for cards with multiple faces, under the name of each face.
+func (idx *nameIndex) add(name string, c *Card) {
+	for _, n := range append([]string{name}, faceNames(name)...) {
+		key := normalizeName(n)
+		if key == "" || containsCard(idx.cards[key], c) {
+			continue
+		}
+		idx.cards[key] = append(idx.cards[key], c)
+		if _, ok := idx.display[key]; !ok {
+			idx.display[key] = n
+		}
+	}
+}
+
+func (idx *nameIndex) get(name string) []*Card {
+	return idx.cards[normalizeName(name)]
+}
+
+type suggestion struct {
+	name  string
+	class int // 0: prefix match, 1: substring match, 2: typo.
+	dist  int
+}
+
+// suggest returns up to max names that look like name, best matches first.
+func (idx *nameIndex) suggest(name string, max int) []string {
+	query := normalizeName(name)
+	if query == "" || max <= 0 {
+		return nil
+	}
+	maxDist := len([]rune(query)) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+
+	var ls []suggestion
+	for key, display := range idx.display {
+		s := suggestion{name: display, dist: levenshtein(query, key)}
+		switch {
+		case strings.HasPrefix(key, query):
+			s.class = 0
+		case strings.Contains(key, query):
+			s.class = 1
+		case s.dist <= maxDist:
+			s.class = 2
+		default:
+			continue
+		}
+		ls = append(ls, s)
+	}
+
+	sort.Slice(ls, func(i, j int) bool {
+		if ls[i].class != ls[j].class {
+			return ls[i].class < ls[j].class
+		}
+		if ls[i].dist != ls[j].dist {
+			return ls[i].dist < ls[j].dist
+		}
+		return ls[i].name < ls[j].name
+	})
+
+	res := make([]string, 0, max)
+	for i := 0; i < len(ls) && i < max; i++ {
+		res = append(res, ls[i].name)
+	}
+	return res
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
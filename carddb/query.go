@@ -0,0 +1,401 @@
+package carddb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Predicate is a function that selects cards, as used by CardDB.Filter.
+type Predicate func(Card) bool
+
+var queryTermRegexp = regexp.MustCompile(`^([a-zA-Z]+)(:|>=|<=|!=|=|<|>)(.+)$`)
+
+var rarityNames = map[string]uint64{
+	"token":    TokenRarity,
+	"t":        TokenRarity,
+	"basic":    BasicLandRarity,
+	"b":        BasicLandRarity,
+	"common":   CommonRarity,
+	"c":        CommonRarity,
+	"uncommon": UncommonRarity,
+	"u":        UncommonRarity,
+	"rare":     RareRarity,
+	"r":        RareRarity,
+	"mythic":   MythicRarity,
+	"m":        MythicRarity,
+}
+
+// ParseQuery parses a query into a Predicate.
+//
+// The query syntax is a small subset of the Scryfall syntax. A query is a list of terms, and a card
+// matches the query if it matches all of them. Terms can be negated with "-", joined with "or" and
+// grouped with parentheses. A term is either a word (or a quoted string) that must be part of the
+// card name, or a key, an operator and a value:
+//
+//	set:THB r>=rare t:creature c:g cmc<=3 o:"draw a card"
+//
+// The supported keys are:
+//
+//	set, s, e       set code.
+//	r, rarity       token, basic, common, uncommon, rare or mythic (or their first letter).
+//	t, type         part of the type line.
+//	c, color        colors, like "rg". "c" is colorless and "m" multicolored. c:rg means at least red and green.
+//	id, identity    color identity. id:rg means that the identity fits in red and green.
+//	cmc, mv         mana value.
+//	m, mana         mana cost, like "{2}{G}{G}" or "2GG". m:2G means that the cost has at least those symbols.
+//	pow, tou        power and toughness.
+//	o, oracle       part of the rules text.
+//	kw, keyword     keyword ability.
+//	n, name         part of the card name.
+//	cn, number      collector number.
+//	is              token, collectible or craftable.
+//
+// The operators are ":", "=", "!=", "<", "<=", ">" and ">=". Not all the keys support all of them.
+func ParseQuery(query string) (Predicate, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	pred, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return pred, nil
+}
+
+func (db *cardDB) Query(query string) ([]Card, error) {
+	pred, err := ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", query, err)
+	}
+	return db.Filter(pred), nil
+}
+
+// tokenizeQuery splits a query into parentheses and terms. Terms can contain quoted strings with spaces.
+func tokenizeQuery(query string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	quoted := false
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case quoted:
+			cur.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) parseOr() (Predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c Card) bool { return l(c) || right(c) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (Predicate, error) {
+	var preds []Predicate
+	for {
+		tok := p.peek()
+		if tok == "" || tok == ")" || strings.EqualFold(tok, "or") {
+			break
+		}
+		if strings.EqualFold(tok, "and") {
+			p.pos++
+			continue
+		}
+		pred, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	if len(preds) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	return func(c Card) bool {
+		for _, pred := range preds {
+			if !pred(c) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (p *queryParser) parseUnary() (Predicate, error) {
+	tok := p.peek()
+	p.pos++
+	if tok == "(" {
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return pred, nil
+	}
+	if len(tok) > 1 && tok[0] == '-' {
+		pred, err := parseQueryTerm(tok[1:])
+		if err != nil {
+			return nil, err
+		}
+		return func(c Card) bool { return !pred(c) }, nil
+	}
+	if tok == "-" {
+		pred, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(c Card) bool { return !pred(c) }, nil
+	}
+	return parseQueryTerm(tok)
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseQueryTerm(term string) (Predicate, error) {
+	m := queryTermRegexp.FindStringSubmatch(term)
+	if m == nil {
+		name := normalizeName(unquote(term))
+		return func(c Card) bool { return strings.Contains(normalizeName(c.Name), name) }, nil
+	}
+	key, op, value := strings.ToLower(m[1]), m[2], unquote(m[3])
+
+	switch key {
+	case "set", "s", "e":
+		return stringTerm(op, value, func(c Card) string { return c.Set })
+	case "cn", "number":
+		return stringTerm(op, value, func(c Card) string { return c.CollectorNumber })
+	case "r", "rarity":
+		rarity, ok := rarityNames[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("unknown rarity %q", value)
+		}
+		return numberTerm(op, int64(rarity), func(c Card) (int64, bool) { return int64(c.Rarity), true })
+	case "cmc", "mv":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mana value %q", value)
+		}
+		return numberTerm(op, n, func(c Card) (int64, bool) { return int64(c.Cmc), true })
+	case "m", "mana":
+		cost, err := ParseManaCost(value)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case ":", ">=":
+			return func(c Card) bool { return c.ManaCost.contains(cost) }, nil
+		case "=":
+			return func(c Card) bool { return c.ManaCost.equal(cost) }, nil
+		case "!=":
+			return func(c Card) bool { return !c.ManaCost.equal(cost) }, nil
+		}
+		return nil, fmt.Errorf("unsupported operator %q for %s", op, key)
+	case "pow", "power", "tou", "toughness":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		stat := func(c Card) CardStat { return c.Power }
+		if key == "tou" || key == "toughness" {
+			stat = func(c Card) CardStat { return c.Toughness }
+		}
+		return numberTerm(op, n, func(c Card) (int64, bool) {
+			v, err := strconv.ParseInt(string(stat(c)), 10, 64)
+			return v, err == nil
+		})
+	case "t", "type":
+		return containsTerm(op, value, func(c Card) string { return c.TypeLine })
+	case "o", "oracle":
+		return containsTerm(op, value, func(c Card) string { return c.RulesText })
+	case "n", "name":
+		return containsTerm(op, value, func(c Card) string { return c.Name })
+	case "kw", "keyword":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("unsupported operator %q for %s", op, key)
+		}
+		return func(c Card) bool { return c.HasKeyword(value) }, nil
+	case "c", "color":
+		return colorTerm(op, ">=", value, func(c Card) []uint64 { return c.Colors })
+	case "id", "identity":
+		return colorTerm(op, "<=", value, func(c Card) []uint64 { return c.ColorIdentity })
+	case "is":
+		if op != ":" {
+			return nil, fmt.Errorf("unsupported operator %q for %s", op, key)
+		}
+		switch strings.ToLower(value) {
+		case "token":
+			return func(c Card) bool { return c.IsToken }, nil
+		case "collectible":
+			return func(c Card) bool { return c.IsCollectible }, nil
+		case "craftable":
+			return func(c Card) bool { return c.IsCraftable }, nil
+		}
+		return nil, fmt.Errorf("unknown property %q", value)
+	}
+	return nil, fmt.Errorf("unknown key %q", key)
+}
+
+func stringTerm(op string, value string, field func(Card) string) (Predicate, error) {
+	switch op {
+	case ":", "=":
+		return func(c Card) bool { return strings.EqualFold(field(c), value) }, nil
+	case "!=":
+		return func(c Card) bool { return !strings.EqualFold(field(c), value) }, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func containsTerm(op string, value string, field func(Card) string) (Predicate, error) {
+	value = strings.ToLower(value)
+	switch op {
+	case ":", "=":
+		return func(c Card) bool { return strings.Contains(strings.ToLower(field(c)), value) }, nil
+	case "!=":
+		return func(c Card) bool { return !strings.Contains(strings.ToLower(field(c)), value) }, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+// numberTerm compares a numeric field against n. field returns false if the card has no numeric value,
+// in which case the card doesn't match.
+func numberTerm(op string, n int64, field func(Card) (int64, bool)) (Predicate, error) {
+	var cmp func(int64) bool
+	switch op {
+	case ":", "=":
+		cmp = func(v int64) bool { return v == n }
+	case "!=":
+		cmp = func(v int64) bool { return v != n }
+	case "<":
+		cmp = func(v int64) bool { return v < n }
+	case "<=":
+		cmp = func(v int64) bool { return v <= n }
+	case ">":
+		cmp = func(v int64) bool { return v > n }
+	case ">=":
+		cmp = func(v int64) bool { return v >= n }
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	return func(c Card) bool {
+		v, ok := field(c)
+		return ok && cmp(v)
+	}, nil
+}
+
+func colorMask(colors []uint64) uint {
+	var mask uint
+	for _, c := range colors {
+		mask |= 1 << c
+	}
+	return mask
+}
+
+func bitCount(mask uint) int {
+	n := 0
+	for ; mask != 0; mask &= mask - 1 {
+		n++
+	}
+	return n
+}
+
+// colorTerm compares a set of colors. The ":" operator behaves like colonOp.
+func colorTerm(op string, colonOp string, value string, field func(Card) []uint64) (Predicate, error) {
+	switch strings.ToLower(value) {
+	case "m", "multicolor":
+		return func(c Card) bool { return bitCount(colorMask(field(c))) > 1 }, nil
+	case "c", "colorless":
+		value = ""
+		if op == ":" {
+			op = "="
+		}
+	}
+
+	var want uint
+	for _, r := range value {
+		color, ok := ParseColor(string(r))
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", r)
+		}
+		want |= 1 << uint(color)
+	}
+
+	if op == ":" {
+		op = colonOp
+	}
+	var cmp func(uint) bool
+	switch op {
+	case "=":
+		cmp = func(m uint) bool { return m == want }
+	case "!=":
+		cmp = func(m uint) bool { return m != want }
+	case ">=":
+		cmp = func(m uint) bool { return m&want == want }
+	case ">":
+		cmp = func(m uint) bool { return m&want == want && m != want }
+	case "<=":
+		cmp = func(m uint) bool { return m&^want == 0 }
+	case "<":
+		cmp = func(m uint) bool { return m&^want == 0 && m != want }
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	return func(c Card) bool { return cmp(colorMask(field(c))) }, nil
+}
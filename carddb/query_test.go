@@ -0,0 +1,105 @@
+package carddb
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+var queryCardsJSON = `[
+	{"grpid": 1, "titleId": 100, "set": "M19", "CollectorNumber": "314", "rarity": 2, "cmc": 1, "castingcost": "oG", "power": 1, "toughness": 1,
+	 "colors": [5], "colorIdentity": [5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 201,
+	 "abilities": [{"abilityId": 10, "textId": 300}]},
+	{"grpid": 2, "titleId": 101, "set": "THB", "CollectorNumber": "51", "rarity": 5, "cmc": 3, "castingcost": "o1oGoU", "power": 3, "toughness": 3,
+	 "colors": [2, 5], "colorIdentity": [2, 5], "types": [2], "cardTypeTextId": 200, "subtypeTextId": 202,
+	 "abilities": [{"abilityId": 11, "textId": 301}]},
+	{"grpid": 3, "titleId": 102, "set": "THB", "CollectorNumber": "60", "rarity": 4, "cmc": 2, "castingcost": "o1oU",
+	 "colors": [2], "colorIdentity": [2], "types": [4], "cardTypeTextId": 203,
+	 "abilities": [{"abilityId": 12, "textId": 302}]},
+	{"grpid": 4, "titleId": 103, "set": "THB", "CollectorNumber": "230", "rarity": 4, "cmc": 0, "castingcost": "o0",
+	 "colors": [], "colorIdentity": [], "types": [1], "cardTypeTextId": 204}
+]`
+
+var queryTextsJSON = `[
+	{"isoCode": "en-US", "keys": [
+		{"id": 100, "text": "Llanowar Elves"},
+		{"id": 101, "text": "Mantle of Tides"},
+		{"id": 102, "text": "Thirst for Meaning"},
+		{"id": 103, "text": "Mirror Shield"},
+		{"id": 200, "text": "Creature"},
+		{"id": 201, "text": "Elf Druid"},
+		{"id": 202, "text": "Merfolk"},
+		{"id": 203, "text": "Instant"},
+		{"id": 204, "text": "Artifact"},
+		{"id": 300, "text": "{oT}: Add {oG}."},
+		{"id": 301, "text": "Whenever you draw a card, scry 1."},
+		{"id": 302, "text": "Draw three cards."}
+	]}
+]`
+
+func TestQuery(t *testing.T) {
+	db, err := NewLibrary(strings.NewReader(queryCardsJSON), strings.NewReader(queryTextsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []uint64
+	}{
+		{"set:THB", []uint64{2, 3, 4}},
+		{"set:thb r>=rare", []uint64{2, 3, 4}},
+		{"r=mythic", []uint64{2}},
+		{"t:creature", []uint64{1, 2}},
+		{"t:elf", []uint64{1}},
+		{"c:g", []uint64{1, 2}},
+		{"c=g", []uint64{1}},
+		{"c:c", []uint64{4}},
+		{"c:m", []uint64{2}},
+		{"id:u", []uint64{3, 4}},
+		{"cmc<=2", []uint64{1, 3, 4}},
+		{"pow>=2", []uint64{2}},
+		{`o:"draw a card"`, []uint64{2}},
+		{"o:draw -t:creature", []uint64{3}},
+		{"llanowar", []uint64{1}},
+		{`"mirror shield"`, []uint64{4}},
+		{"set:M19 or r:m", []uint64{1, 2}},
+		{"-(t:creature or t:artifact)", []uint64{3}},
+		{"set:THB cn:51", []uint64{2}},
+		{"m:U", []uint64{2, 3}},
+		{"m:{G}{U}", []uint64{2}},
+		{"m=1U", []uint64{3}},
+		{"m:2", nil},
+	}
+
+	for _, test := range tests {
+		cards, err := db.Query(test.query)
+		if err != nil {
+			t.Errorf("Query(%q) failed: %v", test.query, err)
+			continue
+		}
+		var got []uint64
+		for _, c := range cards {
+			got = append(got, c.ID)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if len(got) != len(test.want) {
+			t.Errorf("Query(%q) = %v, want %v", test.query, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Query(%q) = %v, want %v", test.query, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, q := range []string{"", "r:legendary", "cmc>=x", "c:q", `o:"draw`, "(t:creature", "foo:bar", "set>THB", "m:Q", "m<G"} {
+		if _, err := ParseQuery(q); err == nil {
+			t.Errorf("ParseQuery(%q) should have failed", q)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package carddb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ReloadStatus describes the state of a ReloadingLibrary.
+type ReloadStatus struct {
+	// Hashes are the hashes of the resource files of the current database, see ResourcePaths.Hashes.
+	Hashes map[string]string `json:"hashes"`
+	// Loaded is when the current database was loaded.
+	Loaded time.Time `json:"loaded"`
+	// LastCheck is the last time the Data folder was checked for new resource files.
+	LastCheck time.Time `json:"lastCheck"`
+	// Reloads is the number of times the database was replaced since it was created.
+	Reloads int `json:"reloads"`
+	// Reloading is set while a new database is being built.
+	Reloading bool `json:"reloading"`
+	// Error is the error of the last reload, empty if it succeeded. The previous database is kept
+	// when a reload fails.
+	Error string `json:"error,omitempty"`
+}
+
+// ReloadingLibrary is a CardDB that reloads itself when the game updates the resource files in the
+// Data folder. Readers always see a complete database: the new database is built in the background,
+// and replaces the old one once it is ready. Cards returned before a reload stay valid, but they
+// belong to the old database.
+type ReloadingLibrary struct {
+	mtgDataPath string
+	cacheDir    string
+	lang        string
+
+	// reloadMu serializes the reloads.
+	reloadMu sync.Mutex
+
+	mu     sync.RWMutex
+	db     CardDB
+	key    string
+	status ReloadStatus
+}
+
+// NewReloadingLibrary loads the card database in mtgDataPath, like CreateCachedLibrary. Call Watch to
+// reload it when the resource files change.
+func NewReloadingLibrary(mtgDataPath string, cacheDir string, lang string) (*ReloadingLibrary, error) {
+	l := &ReloadingLibrary{mtgDataPath: mtgDataPath, cacheDir: cacheDir, lang: lang}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload checks if the resource files in the Data folder changed, and if they did, loads the new
+// database. It returns whether the database was replaced.
+func (l *ReloadingLibrary) Reload() (bool, error) {
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+
+	// Only log the choice of files on the first load, Watch logs the files used by a reload.
+	paths, err := findMTGAResourceFiles(l.mtgDataPath, l.Current() == nil)
+	now := time.Now()
+	l.mu.Lock()
+	l.status.LastCheck = now
+	changed := err == nil && paths.key() != l.key
+	l.status.Reloading = changed
+	l.mu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("failed to find mtga resource files: %v", err)
+		l.setError(err)
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+
+	db, err := CreateLibraryFromPaths(paths, l.cacheDir, l.lang)
+	if err != nil {
+		l.setError(err)
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.db != nil {
+		l.status.Reloads++
+	}
+	l.db = db
+	l.key = paths.key()
+	l.status.Hashes = paths.Hashes()
+	l.status.Loaded = time.Now()
+	l.status.Reloading = false
+	l.status.Error = ""
+	return true, nil
+}
+
+func (l *ReloadingLibrary) setError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.status.Reloading = false
+	l.status.Error = err.Error()
+}
+
+// Watch checks the Data folder for new resource files every interval, and reloads the database when
+// they change, until ctx is done. The resource files have a hash in their name, so polling the folder
+// is cheap. Failed reloads are logged and retried in the next check.
+func (l *ReloadingLibrary) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := l.Reload()
+		if err != nil {
+			log.Printf("Failed to reload card database: %v", err)
+		} else if reloaded {
+			log.Printf("Reloaded card database: %v", l.Status().Hashes)
+		}
+	}
+}
+
+// Status returns the current state of the library.
+func (l *ReloadingLibrary) Status() ReloadStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := l.status
+	s.Hashes = make(map[string]string)
+	for k, v := range l.status.Hashes {
+		s.Hashes[k] = v
+	}
+	return s
+}
+
+// Current returns the current database. Use it to run several lookups against the same version of
+// the database.
+func (l *ReloadingLibrary) Current() CardDB {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.db
+}
+
+func (l *ReloadingLibrary) GetCard(name string) []*Card {
+	return l.Current().GetCard(name)
+}
+
+func (l *ReloadingLibrary) SuggestNames(name string, max int) []string {
+	return l.Current().SuggestNames(name, max)
+}
+
+func (l *ReloadingLibrary) GetCardByID(id uint64) *Card {
+	return l.Current().GetCardByID(id)
+}
+
+func (l *ReloadingLibrary) GetCardBySetNumber(set string, collectorNumber string) *Card {
+	return l.Current().GetCardBySetNumber(set, collectorNumber)
+}
+
+func (l *ReloadingLibrary) CardsInSet(set string) []*Card {
+	return l.Current().CardsInSet(set)
+}
+
+func (l *ReloadingLibrary) GetCardsByTitleID(titleID uint64) []*Card {
+	return l.Current().GetCardsByTitleID(titleID)
+}
+
+func (l *ReloadingLibrary) TokenCreators(tokenID uint64) []*Card {
+	return l.Current().TokenCreators(tokenID)
+}
+
+func (l *ReloadingLibrary) CardsCreatingToken(name string) []*Card {
+	return l.Current().CardsCreatingToken(name)
+}
+
+func (l *ReloadingLibrary) ForEach(f func(Card)) {
+	l.Current().ForEach(f)
+}
+
+func (l *ReloadingLibrary) Filter(predicate func(Card) bool) []Card {
+	return l.Current().Filter(predicate)
+}
+
+func (l *ReloadingLibrary) Query(query string) ([]Card, error) {
+	return l.Current().Query(query)
+}
+
+func (l *ReloadingLibrary) Language() string {
+	return l.Current().Language()
+}
+
+func (l *ReloadingLibrary) Languages() []string {
+	return l.Current().Languages()
+}
+
+func (l *ReloadingLibrary) LocalizedName(c *Card, lang string) string {
+	return l.Current().LocalizedName(c, lang)
+}
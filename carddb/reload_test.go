@@ -0,0 +1,74 @@
+package carddb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadingLibrary(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "carddb-reload")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	// Each version of the files is written an hour after the previous one.
+	version := time.Now().Add(-time.Hour)
+	writeFile := func(name, contents string) {
+		path := filepath.Join(dataDir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write %q: %v", name, err)
+		}
+		if err := os.Chtimes(path, version, version); err != nil {
+			t.Fatalf("failed to set the time of %q: %v", name, err)
+		}
+	}
+	writeFile("data_cards_aaa.mtga", queryCardsJSON)
+	writeFile("data_loc_bbb.mtga", queryTextsJSON)
+	version = version.Add(time.Hour)
+
+	l, err := NewReloadingLibrary(dataDir, filepath.Join(dataDir, "cache"), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	if c := l.GetCardByID(1); c == nil || c.Name != "Llanowar Elves" {
+		t.Errorf("wrong card 1: %v", c)
+	}
+	if got := l.Status().Hashes["cards"]; got != "aaa" {
+		t.Errorf("wrong cards hash. want %q, got %q", "aaa", got)
+	}
+
+	if reloaded, err := l.Reload(); err != nil || reloaded {
+		t.Errorf("Reload without changes. want false, nil, got %v, %v", reloaded, err)
+	}
+
+	// While the game is updating, the new cards file might be incomplete. The old database is kept.
+	writeFile("data_cards_ccc.mtga", `[{"grpid": 7,`)
+	if _, err := l.Reload(); err == nil {
+		t.Errorf("Reload with an incomplete cards file should have failed")
+	}
+	if s := l.Status(); s.Error == "" || s.Hashes["cards"] != "aaa" {
+		t.Errorf("wrong status after a failed reload: %+v", s)
+	}
+	if c := l.GetCardByID(1); c == nil {
+		t.Errorf("card 1 missing after a failed reload")
+	}
+
+	writeFile("data_cards_ccc.mtga", `[{"grpid": 7, "titleId": 101}]`)
+	if reloaded, err := l.Reload(); err != nil || !reloaded {
+		t.Fatalf("Reload with new cards file. want true, nil, got %v, %v", reloaded, err)
+	}
+	if c := l.GetCardByID(1); c != nil {
+		t.Errorf("card 1 still present after reload: %v", c)
+	}
+	if c := l.GetCardByID(7); c == nil || c.Name != "Mantle of Tides" {
+		t.Errorf("wrong card 7 after reload: %v", c)
+	}
+	s := l.Status()
+	if s.Hashes["cards"] != "ccc" || s.Reloads != 1 || s.Error != "" || s.Reloading {
+		t.Errorf("wrong status after reload: %+v", s)
+	}
+}
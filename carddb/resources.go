@@ -0,0 +1,275 @@
+package carddb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResourcePaths has the paths to the MTGA resource files used to create a card database.
+// Either Scryfall, CardDatabase, or Cards and Texts are required, the rest can be empty.
+type ResourcePaths struct {
+	Cards     string
+	Texts     string
+	Enums     string
+	Abilities string
+	// CardDatabase is the SQLite card database used by newer versions of the game instead of the
+	// other files.
+	CardDatabase string
+	// Scryfall is a Scryfall bulk data file (see parseScryfallFile), used instead of the game files.
+	Scryfall string
+}
+
+// resourceHash returns the hash in the name of a resource file: data_cards_<hash>.mtga.
+func resourceHash(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return name[strings.LastIndex(name, "_")+1:]
+}
+
+// scryfallHash identifies a version of a Scryfall file. They don't have a hash in their name, and they
+// are too big to hash their contents every time the Data folder is checked for changes, so their size
+// and modification time are used instead.
+func scryfallHash(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return path
+	}
+	return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+}
+
+// Hashes returns the hashes in the names of the resource files, by kind of file ("cards", "texts", ...).
+// Files that are not present are not included. For a Scryfall file, the hash is its size and
+// modification time.
+func (p ResourcePaths) Hashes() map[string]string {
+	m := make(map[string]string)
+	for kind, path := range map[string]string{"cards": p.Cards, "texts": p.Texts, "enums": p.Enums, "abilities": p.Abilities, "database": p.CardDatabase} {
+		if path != "" {
+			m[kind] = resourceHash(path)
+		}
+	}
+	if p.Scryfall != "" {
+		m["scryfall"] = scryfallHash(p.Scryfall)
+	}
+	return m
+}
+
+// key identifies the contents of the resource files. Since the names of the files have the hash of
+// their contents, the key changes every time the game updates one of them.
+func (p ResourcePaths) key() string {
+	var ls []string
+	if p.Scryfall != "" {
+		return "scryfall," + scryfallHash(p.Scryfall)
+	}
+	if p.CardDatabase != "" {
+		return "sqlite," + resourceHash(p.CardDatabase)
+	}
+	for _, path := range []string{p.Cards, p.Texts, p.Enums, p.Abilities} {
+		ls = append(ls, resourceHash(path))
+	}
+	return strings.Join(ls, ",")
+}
+
+// ResourceCandidate is a resource file found in the Data folder.
+type ResourceCandidate struct {
+	// Kind is the kind of file: "cards", "texts", "enums", "abilities" or "database".
+	Kind    string
+	Path    string
+	Hash    string
+	ModTime time.Time
+}
+
+// resourceKinds are the prefixes of the names of each kind of resource file.
+var resourceKinds = []struct {
+	kind   string
+	prefix string
+}{
+	{"cards", "data_cards_"},
+	{"texts", "data_loc_"},
+	{"enums", "data_enums_"},
+	{"abilities", "data_abilities_"},
+	{"database", "Raw_CardDatabase_"},
+}
+
+// FindResourceCandidates returns all the resource files in mtgDataPath, and the SQLite card databases
+// in the Raw folder next to it. After an update, the game might leave the files of the previous version
+// around, so there can be more than one file of each kind. The candidates of each kind are sorted from
+// newest to oldest.
+func FindResourceCandidates(mtgDataPath string) ([]ResourceCandidate, error) {
+	var candidates []ResourceCandidate
+	for _, k := range resourceKinds {
+		dirs := []string{mtgDataPath}
+		if k.kind == "database" {
+			dirs = append(dirs, filepath.Join(mtgDataPath, "..", "Raw"))
+		}
+		var files []string
+		for _, dir := range dirs {
+			fs, err := filepath.Glob(filepath.Join(dir, k.prefix+"*"+".mtga"))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, fs...)
+		}
+		var cs []ResourceCandidate
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				return nil, err
+			}
+			cs = append(cs, ResourceCandidate{Kind: k.kind, Path: f, Hash: resourceHash(f), ModTime: fi.ModTime()})
+		}
+		sort.SliceStable(cs, func(i, j int) bool { return cs[i].ModTime.After(cs[j].ModTime) })
+		candidates = append(candidates, cs...)
+	}
+	return candidates, nil
+}
+
+// FindMTGAResourceFiles returns the paths for the resource files needed by carddb, given the Data
+// path for MTG Arena. Typically the files are stored in the mtgDataPath folder, but their names have a hash
+// at the end. This function just try to look in that folder for the correct files.
+// It also looks for the SQLite card database, which newer versions of the game store in the Raw folder
+// next to the Data folder instead of the JSON resource files.
+//
+// When there are files from more than one version of the game, the newest of the cards files and the
+// card databases is used. For the JSON files, the other files are the ones that were written closest
+// to the cards file. A card database is only used instead of the JSON files if SQLite support is built
+// in. Use FindResourceCandidates and
+// CreateLibraryFromPaths to pick the files by hand.
+//
+// If mtgDataPath is a JSON file instead of a folder, it is used as a Scryfall bulk data file.
+func FindMTGAResourceFiles(mtgDataPath string) (ResourcePaths, error) {
+	return findMTGAResourceFiles(mtgDataPath, true)
+}
+
+// findMTGAResourceFiles is FindMTGAResourceFiles, logChoice says whether to log which file was used
+// when there is more than one of a kind.
+func findMTGAResourceFiles(mtgDataPath string, logChoice bool) (ResourcePaths, error) {
+	var paths ResourcePaths
+	if fi, err := os.Stat(mtgDataPath); err == nil && !fi.IsDir() && strings.EqualFold(filepath.Ext(mtgDataPath), ".json") {
+		paths.Scryfall = mtgDataPath
+		return paths, nil
+	}
+	candidates, err := FindResourceCandidates(mtgDataPath)
+	if err != nil {
+		return paths, fmt.Errorf("Failed to look for resource files: %v", err)
+	}
+	byKind := make(map[string][]ResourceCandidate)
+	for _, c := range candidates {
+		byKind[c.Kind] = append(byKind[c.Kind], c)
+	}
+
+	cards, dbs := byKind["cards"], byKind["database"]
+	// After an update the game might leave the JSON files of the previous version in the Data folder.
+	useDatabase := len(dbs) > 0 && (len(cards) == 0 || dbs[0].ModTime.After(cards[0].ModTime))
+	if useDatabase && len(cards) > 0 && !sqliteSupported() {
+		if logChoice {
+			log.Printf("Using the JSON resource files, %s is newer but %v", filepath.Base(dbs[0].Path), errNoSQLite)
+		}
+		useDatabase = false
+	}
+	if useDatabase {
+		paths.CardDatabase = chooseResource(dbs, dbs[0].ModTime, logChoice).Path
+		return paths, nil
+	}
+	if len(cards) == 0 {
+		return paths, fmt.Errorf("Failed to look for cards file: %v", errResourceNotFound)
+	}
+	newest := cards[0].ModTime
+	paths.Cards = chooseResource(cards, newest, logChoice).Path
+	if len(byKind["texts"]) == 0 {
+		return paths, fmt.Errorf("Failed to look for texts file: %v", errResourceNotFound)
+	}
+	paths.Texts = chooseResource(byKind["texts"], newest, logChoice).Path
+	if cs := byKind["enums"]; len(cs) > 0 {
+		paths.Enums = chooseResource(cs, newest, logChoice).Path
+	}
+	if cs := byKind["abilities"]; len(cs) > 0 {
+		paths.Abilities = chooseResource(cs, newest, logChoice).Path
+	}
+	return paths, nil
+}
+
+// chooseResource returns the candidate that was modified closest to t. Files from the same version of
+// the game are written together, so they have similar modification times.
+func chooseResource(candidates []ResourceCandidate, t time.Time, logChoice bool) ResourceCandidate {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if absDuration(c.ModTime.Sub(t)) < absDuration(best.ModTime.Sub(t)) {
+			best = c
+		}
+	}
+	if logChoice && len(candidates) > 1 {
+		log.Printf("Found %d %s files, using %s", len(candidates), best.Kind, filepath.Base(best.Path))
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+var errResourceNotFound = errors.New("resource file not found")
+
+// parseResourcePaths opens and parses all the resource files in paths.
+func parseResourcePaths(paths ResourcePaths) (*resources, error) {
+	if paths.Scryfall != "" {
+		f, err := os.Open(paths.Scryfall)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open scryfall file: %v", err)
+		}
+		defer f.Close()
+		return parseScryfallFile(f)
+	}
+	if paths.CardDatabase != "" {
+		return parseCardDatabase(paths.CardDatabase)
+	}
+	var files ResourceFiles
+	toOpen := []struct {
+		name string
+		path string
+		r    *io.Reader
+	}{
+		{"cards", paths.Cards, &files.Cards},
+		{"texts", paths.Texts, &files.Texts},
+		{"enums", paths.Enums, &files.Enums},
+		{"abilities", paths.Abilities, &files.Abilities},
+	}
+	for _, o := range toOpen {
+		if o.path == "" {
+			continue
+		}
+		f, err := os.Open(o.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s resource file: %v", o.name, err)
+		}
+		defer f.Close()
+		*o.r = f
+	}
+	return parseResources(files)
+}
+
+// CreateLibrary is a helper function for NewLibrary, it takes the Data path inside the MTG installation
+// directory, and tries to find the required resource files for creating the database. Both the JSON
+// resource files and the SQLite card database are supported. mtgDataPath can also be a Scryfall bulk
+// data file, for when the game is not installed.
+// The card names are in english, use CreateLocalizedLibrary to pick a different language.
+func CreateLibrary(mtgDataPath string) (CardDB, error) {
+	return CreateLocalizedLibrary(mtgDataPath, "en-US")
+}
+
+// CreateLocalizedLibrary is like CreateLibrary, but the card names are in the given language.
+func CreateLocalizedLibrary(mtgDataPath string, lang string) (CardDB, error) {
+	paths, err := FindMTGAResourceFiles(mtgDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mtga resource files: %v", err)
+	}
+	return CreateLibraryFromPaths(paths, "", lang)
+}
@@ -0,0 +1,121 @@
+package carddb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindMTGAResourceFiles(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "carddb-resources")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	now := time.Now()
+	writeFile := func(name string, age time.Duration) {
+		path := filepath.Join(dataDir, name)
+		if err := ioutil.WriteFile(path, []byte("[]"), 0644); err != nil {
+			t.Fatalf("failed to write %q: %v", name, err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("failed to set the time of %q: %v", name, err)
+		}
+	}
+	// An old version of the game, and a newer one that didn't update the enums.
+	writeFile("data_cards_old.mtga", 48*time.Hour)
+	writeFile("data_loc_old.mtga", 48*time.Hour)
+	writeFile("data_enums_old.mtga", 48*time.Hour)
+	writeFile("data_abilities_old.mtga", 48*time.Hour)
+	writeFile("data_cards_new.mtga", time.Hour)
+	writeFile("data_loc_new.mtga", time.Hour+time.Minute)
+	writeFile("data_abilities_new.mtga", time.Hour)
+	// A texts file from the future version, which doesn't have its cards file yet.
+	writeFile("data_loc_next.mtga", 0)
+
+	candidates, err := FindResourceCandidates(dataDir)
+	if err != nil {
+		t.Fatalf("FindResourceCandidates failed: %v", err)
+	}
+	if len(candidates) != 8 {
+		t.Errorf("wrong number of candidates. want 8, got %d: %v", len(candidates), candidates)
+	}
+	if c := candidates[0]; c.Kind != "cards" || c.Hash != "new" {
+		t.Errorf("wrong first candidate. want the new cards file, got %+v", c)
+	}
+
+	paths, err := FindMTGAResourceFiles(dataDir)
+	if err != nil {
+		t.Fatalf("FindMTGAResourceFiles failed: %v", err)
+	}
+	want := map[string]string{"cards": "new", "texts": "new", "enums": "old", "abilities": "new"}
+	got := paths.Hashes()
+	for kind, hash := range want {
+		if got[kind] != hash {
+			t.Errorf("wrong %s file. want %q, got %q", kind, hash, got[kind])
+		}
+	}
+
+	os.Remove(filepath.Join(dataDir, "data_loc_new.mtga"))
+	os.Remove(filepath.Join(dataDir, "data_loc_old.mtga"))
+	os.Remove(filepath.Join(dataDir, "data_loc_next.mtga"))
+	if _, err := FindMTGAResourceFiles(dataDir); err == nil {
+		t.Errorf("FindMTGAResourceFiles without texts files should have failed")
+	}
+}
+
+func TestFindMTGAResourceFilesDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carddb-resources")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dataDir := filepath.Join(dir, "Data")
+	rawDir := filepath.Join(dir, "Raw")
+	for _, d := range []string{dataDir, rawDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("failed to create %q: %v", d, err)
+		}
+	}
+
+	now := time.Now()
+	writeFile := func(path string, age time.Duration) {
+		if err := ioutil.WriteFile(path, []byte("[]"), 0644); err != nil {
+			t.Fatalf("failed to write %q: %v", path, err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("failed to set the time of %q: %v", path, err)
+		}
+	}
+	// The JSON files of an old version of the game, that now uses the card database.
+	writeFile(filepath.Join(dataDir, "data_cards_old.mtga"), 48*time.Hour)
+	writeFile(filepath.Join(dataDir, "data_loc_old.mtga"), 48*time.Hour)
+	writeFile(filepath.Join(rawDir, "Raw_CardDatabase_new.mtga"), time.Hour)
+
+	paths, err := FindMTGAResourceFiles(dataDir)
+	if err != nil {
+		t.Fatalf("FindMTGAResourceFiles failed: %v", err)
+	}
+	want := ResourcePaths{CardDatabase: filepath.Join(rawDir, "Raw_CardDatabase_new.mtga")}
+	if !sqliteSupported() {
+		// The database can't be read, the JSON files are the only option.
+		want = ResourcePaths{Cards: filepath.Join(dataDir, "data_cards_old.mtga"), Texts: filepath.Join(dataDir, "data_loc_old.mtga")}
+	}
+	if paths != want {
+		t.Errorf("wrong resource files with a newer database.\nwant %+v\ngot  %+v", want, paths)
+	}
+
+	// The game went back to the JSON files.
+	writeFile(filepath.Join(dataDir, "data_cards_next.mtga"), 0)
+	writeFile(filepath.Join(dataDir, "data_loc_next.mtga"), 0)
+	paths, err = FindMTGAResourceFiles(dataDir)
+	if err != nil {
+		t.Fatalf("FindMTGAResourceFiles failed: %v", err)
+	}
+	if got := paths.Hashes(); got["cards"] != "next" || got["texts"] != "next" || got["database"] != "" {
+		t.Errorf("wrong resource files with newer JSON files: %+v", paths)
+	}
+}
@@ -0,0 +1,330 @@
+package carddb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// scryfallArenaSets are the Arena codes of the sets that have a different code in Scryfall. The list is
+// not complete, it only has the differences that are known. The other sets use the Scryfall code in
+// upper case.
+var scryfallArenaSets = map[string]string{
+	"dom": "DAR",
+}
+
+// arenaSetCode returns the Arena code of a Scryfall set.
+func arenaSetCode(set string) string {
+	if code, ok := scryfallArenaSets[strings.ToLower(set)]; ok {
+		return code
+	}
+	return strings.ToUpper(set)
+}
+
+// scryfallFaceIDBase is the first ID used for the secondary faces of cards loaded from Scryfall.
+const scryfallFaceIDBase = 1 << 40
+
+// scryfallCard are the fields of a Scryfall card object used by carddb.
+type scryfallCard struct {
+	ID              string              `json:"id"`
+	ArenaID         uint64              `json:"arena_id"`
+	Lang            string              `json:"lang"`
+	Layout          string              `json:"layout"`
+	Set             string              `json:"set"`
+	CollectorNumber string              `json:"collector_number"`
+	Rarity          string              `json:"rarity"`
+	Artist          string              `json:"artist"`
+	ColorIdentity   []string            `json:"color_identity"`
+	Keywords        []string            `json:"keywords"`
+	CardFaces       []scryfallCardFace  `json:"card_faces"`
+	AllParts        []scryfallRelatedID `json:"all_parts"`
+	scryfallCardFace
+}
+
+// scryfallCardFace has the fields that are per face in multi-faced cards, and in the card itself in
+// the rest.
+type scryfallCardFace struct {
+	Name       string   `json:"name"`
+	ManaCost   string   `json:"mana_cost"`
+	TypeLine   string   `json:"type_line"`
+	OracleText string   `json:"oracle_text"`
+	Power      CardStat `json:"power"`
+	Toughness  CardStat `json:"toughness"`
+	Colors     []string `json:"colors"`
+}
+
+type scryfallRelatedID struct {
+	ID        string `json:"id"`
+	Component string `json:"component"`
+}
+
+var scryfallRarities = map[string]uint64{
+	"common":   CommonRarity,
+	"uncommon": UncommonRarity,
+	"rare":     RareRarity,
+	"special":  RareRarity,
+	"mythic":   MythicRarity,
+	"bonus":    MythicRarity,
+}
+
+// scryfallFaceTypes are the LinkedFaceType of the first and the other faces of each Scryfall layout
+// with more than one face.
+var scryfallFaceTypes = map[string][2]LinkedFaceType{
+	"transform": {DFCFrontFace, DFCBackFace},
+	"modal_dfc": {MDFCFrontFace, MDFCBackFace},
+	"adventure": {AdventurerFace, AdventureFace},
+	"split":     {SplitCardFace, SplitHalfFace},
+}
+
+// scryfallSupertypes are the words of a type line that are supertypes.
+var scryfallSupertypes = map[string]bool{
+	"Basic":     true,
+	"Legendary": true,
+	"Ongoing":   true,
+	"Snow":      true,
+	"World":     true,
+}
+
+// scryfallBuilder creates the resources for the cards in a Scryfall file, making up the text, enum and
+// ability IDs.
+type scryfallBuilder struct {
+	res       *resources
+	textIDs   map[string]uint64
+	keywords  map[string]uint64 // ability ID of each keyword.
+	nextAbID  uint64
+	nextFace  uint64
+	byScryID  map[string]uint64 // arena ID by scryfall ID.
+	tokenRefs map[uint64][]string
+}
+
+func (b *scryfallBuilder) text(s string) uint64 {
+	if id, ok := b.textIDs[s]; ok {
+		return id
+	}
+	id := uint64(len(b.textIDs) + 1)
+	b.textIDs[s] = id
+	b.res.Locs[0].Texts[id] = s
+	return id
+}
+
+func (b *scryfallBuilder) enumValue(enum string, name string) uint64 {
+	values, ok := b.res.Enums[enum]
+	if !ok {
+		values = make(map[uint64]uint64)
+		b.res.Enums[enum] = values
+	}
+	textID := b.text(name)
+	for v, t := range values {
+		if t == textID {
+			return v
+		}
+	}
+	v := uint64(len(values) + 1)
+	values[v] = textID
+	return v
+}
+
+func (b *scryfallBuilder) ability(textID uint64, baseID uint64) CardAbilityRef {
+	b.nextAbID++
+	b.res.Abilities[b.nextAbID] = abilityJSON{ID: b.nextAbID, TextID: textID, BaseID: baseID}
+	return CardAbilityRef{AbilityID: b.nextAbID, TextID: textID}
+}
+
+// face returns the card for one face of c.
+func (b *scryfallBuilder) face(c *scryfallCard, f *scryfallCardFace) (CardJSON, error) {
+	card := CardJSON{
+		TitleID:         b.text(f.Name),
+		Set:             arenaSetCode(c.Set),
+		CollectorNumber: c.CollectorNumber,
+		ArtistCredit:    c.Artist,
+		Power:           f.Power,
+		Toughness:       f.Toughness,
+		Rarity:          scryfallRarities[c.Rarity],
+		IsCollectible:   true,
+	}
+	mc, err := ParseManaCost(f.ManaCost)
+	if err != nil {
+		return card, err
+	}
+	card.CastingCost = mc.ArenaString()
+	card.Cmc = mc.ManaValue()
+
+	colors := f.Colors
+	if colors == nil {
+		colors = c.Colors
+	}
+	for _, s := range colors {
+		if color, ok := ParseColor(s); ok {
+			card.Colors = append(card.Colors, uint64(color))
+		}
+	}
+	for _, s := range c.ColorIdentity {
+		if color, ok := ParseColor(s); ok {
+			card.ColorIdentity = append(card.ColorIdentity, uint64(color))
+		}
+	}
+
+	types, subtypes := f.TypeLine, ""
+	if i := strings.Index(f.TypeLine, "—"); i >= 0 {
+		types, subtypes = f.TypeLine[:i], f.TypeLine[i+len("—"):]
+	}
+	for _, t := range strings.Fields(types) {
+		if t == "Token" {
+			// Arena doesn't have a Token type, tokens are marked with IsToken.
+			continue
+		}
+		if scryfallSupertypes[t] {
+			card.Supertypes = append(card.Supertypes, b.enumValue(supertypeEnum, t))
+		} else {
+			card.Types = append(card.Types, b.enumValue(cardTypeEnum, t))
+		}
+	}
+	for _, t := range strings.Fields(subtypes) {
+		card.Subtypes = append(card.Subtypes, b.enumValue(subtypeEnum, t))
+	}
+
+	if f.OracleText != "" {
+		for _, line := range strings.Split(f.OracleText, "\n") {
+			card.Abilities = append(card.Abilities, b.ability(b.text(line), 0))
+		}
+	}
+
+	switch {
+	case c.Layout == "token":
+		card.IsToken = true
+		card.IsCollectible = false
+		card.Rarity = TokenRarity
+	case strings.Contains(types, "Basic") && strings.Contains(types, "Land"):
+		card.Rarity = BasicLandRarity
+	}
+	card.IsCraftable = card.IsCollectible && card.Rarity > BasicLandRarity
+	return card, nil
+}
+
+// add adds the cards for c to the resources.
+func (b *scryfallBuilder) add(c *scryfallCard) error {
+	faceTypes, multiFaced := scryfallFaceTypes[c.Layout]
+	first := &c.scryfallCardFace
+	if multiFaced && len(c.CardFaces) > 0 {
+		first = &c.CardFaces[0]
+	}
+	if c.Layout == "split" && len(c.CardFaces) > 0 {
+		// The whole split card has the full name, and the costs and texts of both halves.
+		whole := *first
+		whole.Name = c.Name
+		whole.ManaCost = strings.Replace(c.ManaCost, " // ", "", -1)
+		var texts []string
+		for _, f := range c.CardFaces {
+			texts = append(texts, f.OracleText)
+		}
+		whole.OracleText = strings.Join(texts, "\n")
+		first = &whole
+	}
+	card, err := b.face(c, first)
+	if err != nil {
+		return fmt.Errorf("card %s: %v", c.Name, err)
+	}
+	card.ID = c.ArenaID
+
+	// Keywords don't have their own line in the rules text (e.g. "Flying, vigilance"), so they are
+	// added as abilities without text.
+	for _, kw := range c.Keywords {
+		id, ok := b.keywords[kw]
+		if !ok {
+			id = b.ability(b.text(kw), 0).AbilityID
+			b.keywords[kw] = id
+		}
+		card.Abilities = append(card.Abilities, b.ability(0, id))
+	}
+
+	var faces []CardJSON
+	if multiFaced && len(c.CardFaces) > 1 {
+		card.LinkedFaceType = uint64(faceTypes[0])
+		others := c.CardFaces[1:]
+		if c.Layout == "split" {
+			others = c.CardFaces
+		}
+		for i := range others {
+			face, err := b.face(c, &others[i])
+			if err != nil {
+				return fmt.Errorf("card %s: %v", c.Name, err)
+			}
+			b.nextFace++
+			face.ID = scryfallFaceIDBase + b.nextFace
+			face.LinkedFaceType = uint64(faceTypes[1])
+			face.LinkedFaces = []uint64{card.ID}
+			face.IsCollectible, face.IsCraftable = false, false
+			card.LinkedFaces = append(card.LinkedFaces, face.ID)
+			faces = append(faces, face)
+		}
+	}
+
+	b.byScryID[c.ID] = card.ID
+	for _, p := range c.AllParts {
+		if p.Component == "token" && p.ID != c.ID {
+			b.tokenRefs[card.ID] = append(b.tokenRefs[card.ID], p.ID)
+		}
+	}
+	b.res.Cards = append(b.res.Cards, card)
+	b.res.Cards = append(b.res.Cards, faces...)
+	return nil
+}
+
+// parseScryfallFile reads the resources from a Scryfall bulk data file.
+//
+// Scryfall (https://scryfall.com/docs/api/bulk-data) publishes bulk files with all the cards in JSON.
+// The Default Cards file has every printing, and the printings available in Arena have their grpid in
+// the arena_id field, so the file can be used instead of the game resource files when the game is not
+// installed. Only the cards with an arena_id are loaded, and the texts are only in english.
+//
+// Scryfall has a single object for all the faces of a card, while Arena has a card for each face. The
+// secondary faces get made up IDs, starting at scryfallFaceIDBase. A few sets have different codes in
+// Scryfall and in Arena (e.g. Dominaria is DOM in Scryfall and DAR in Arena), and the Arena codes from
+// scryfallArenaSets are used.
+func parseScryfallFile(r io.Reader) (*resources, error) {
+	b := &scryfallBuilder{
+		res: &resources{
+			Locs:      localizations{{IsoCode: "en-US", LangKey: "EN", Texts: make(map[uint64]string)}},
+			Enums:     make(enums),
+			Abilities: make(map[uint64]abilityJSON),
+		},
+		textIDs:   make(map[string]uint64),
+		keywords:  make(map[string]uint64),
+		byScryID:  make(map[string]uint64),
+		tokenRefs: make(map[uint64][]string),
+	}
+
+	// The files are big, so the cards are decoded one at a time.
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, fmt.Errorf("Failed to decode JSON: expected an array of cards")
+	}
+	seen := make(map[uint64]bool)
+	for dec.More() {
+		var c scryfallCard
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("Failed to decode JSON: %v", err)
+		}
+		if c.ArenaID == 0 || seen[c.ArenaID] || (c.Lang != "" && c.Lang != "en") {
+			continue
+		}
+		seen[c.ArenaID] = true
+		if err := b.add(&c); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("Failed to decode JSON: %v", err)
+	}
+
+	for i := range b.res.Cards {
+		card := &b.res.Cards[i]
+		for _, ref := range b.tokenRefs[card.ID] {
+			if id, ok := b.byScryID[ref]; ok {
+				card.LinkedTokens = append(card.LinkedTokens, id)
+			}
+		}
+	}
+	return b.res, nil
+}
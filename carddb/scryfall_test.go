@@ -0,0 +1,150 @@
+package carddb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const scryfallTestData = `[
+{"id": "elves", "arena_id": 68000, "lang": "en", "layout": "normal", "name": "Llanowar Elves", "set": "m19",
+ "collector_number": "314", "rarity": "common", "mana_cost": "{G}", "type_line": "Creature — Elf",
+ "oracle_text": "{T}: Add {G}.", "power": "1", "toughness": "1", "colors": ["G"], "color_identity": ["G"]},
+{"id": "elves-es", "arena_id": 68000, "lang": "es", "layout": "normal", "name": "Elfos de Llanowar", "set": "m19",
+ "collector_number": "314", "rarity": "common", "mana_cost": "{G}", "type_line": "Creature — Elf"},
+{"id": "paper", "lang": "en", "layout": "normal", "name": "Paper Only", "set": "lea", "collector_number": "1",
+ "rarity": "rare", "mana_cost": "{1}", "type_line": "Artifact"},
+{"id": "giant", "arena_id": 70000, "lang": "en", "layout": "adventure", "name": "Bonecrusher Giant // Stomp",
+ "set": "eld", "collector_number": "115", "rarity": "rare", "mana_cost": "{2}{R} // {1}{R}",
+ "colors": ["R"], "color_identity": ["R"], "keywords": ["Reach"],
+ "card_faces": [
+  {"name": "Bonecrusher Giant", "mana_cost": "{2}{R}", "type_line": "Creature — Giant",
+   "oracle_text": "Whenever Bonecrusher Giant becomes the target of a spell, Bonecrusher Giant deals 2 damage to that spell's controller.",
+   "power": "4", "toughness": "3"},
+  {"name": "Stomp", "mana_cost": "{1}{R}", "type_line": "Instant — Adventure",
+   "oracle_text": "Damage can't be prevented this turn. Stomp deals 2 damage to any target."}]},
+{"id": "fireice", "arena_id": 71000, "lang": "en", "layout": "split", "name": "Fire // Ice", "set": "dmr",
+ "collector_number": "215", "rarity": "uncommon", "mana_cost": "{1}{R} // {1}{U}", "type_line": "Instant // Instant",
+ "colors": ["R", "U"], "color_identity": ["R", "U"],
+ "card_faces": [
+  {"name": "Fire", "mana_cost": "{1}{R}", "type_line": "Instant", "oracle_text": "Fire deals 2 damage divided as you choose among one or two targets."},
+  {"name": "Ice", "mana_cost": "{1}{U}", "type_line": "Instant", "oracle_text": "Tap target permanent.\nDraw a card."}]},
+{"id": "maker", "arena_id": 72000, "lang": "en", "layout": "normal", "name": "Elf Maker", "set": "m19",
+ "collector_number": "200", "rarity": "mythic", "mana_cost": "{3}{G}{G}", "type_line": "Legendary Creature — Elf",
+ "oracle_text": "When Elf Maker enters the battlefield, create a 1/1 green Elf Warrior creature token.",
+ "power": "2", "toughness": "2", "colors": ["G"], "color_identity": ["G"],
+ "all_parts": [{"id": "maker", "component": "combo_piece"}, {"id": "elftoken", "component": "token"}]},
+{"id": "elftoken", "arena_id": 72001, "lang": "en", "layout": "token", "name": "Elf Warrior", "set": "tm19",
+ "collector_number": "10", "rarity": "common", "mana_cost": "", "type_line": "Token Creature — Elf Warrior",
+ "power": "1", "toughness": "1", "colors": ["G"], "color_identity": ["G"]},
+{"id": "shivan", "arena_id": 67000, "lang": "en", "layout": "normal", "name": "Shivan Fire", "set": "dom",
+ "collector_number": "142", "rarity": "common", "mana_cost": "{R}", "type_line": "Instant", "colors": ["R"]},
+{"id": "forest", "arena_id": 68001, "lang": "en", "layout": "normal", "name": "Forest", "set": "m19",
+ "collector_number": "280", "rarity": "common", "mana_cost": "", "type_line": "Basic Land — Forest",
+ "oracle_text": "({T}: Add {G}.)", "colors": [], "color_identity": ["G"]}
+]`
+
+func TestScryfallLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carddb-scryfall")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "default-cards.json")
+	if err := ioutil.WriteFile(path, []byte(scryfallTestData), 0644); err != nil {
+		t.Fatalf("failed to write scryfall file: %v", err)
+	}
+
+	db, err := CreateLibrary(path)
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	elves := db.GetCardByID(68000)
+	if elves == nil || elves.Name != "Llanowar Elves" || elves.Set != "M19" || elves.Rarity != CommonRarity {
+		t.Fatalf("wrong Llanowar Elves: %+v", elves)
+	}
+	if elves.TypeLine != "Creature — Elf" || elves.Power != "1" || elves.Cmc != 1 || elves.ColorString() != "G" {
+		t.Errorf("wrong Llanowar Elves: %+v", *elves)
+	}
+	if elves.RulesText != "{T}: Add {G}." {
+		t.Errorf("wrong rules text: %q", elves.RulesText)
+	}
+	if cs := db.GetCard("Paper Only"); len(cs) != 0 {
+		t.Errorf("cards without arena_id should not be loaded, got %v", cs)
+	}
+
+	giant := db.GetCardByID(70000)
+	if giant == nil || giant.Name != "Bonecrusher Giant" || giant.Cmc != 3 || giant.Power != "4" {
+		t.Fatalf("wrong Bonecrusher Giant: %+v", giant)
+	}
+	if !giant.HasKeyword("reach") {
+		t.Errorf("Bonecrusher Giant should have reach: %+v", giant.Rules)
+	}
+	if len(giant.Faces) != 1 || giant.Faces[0].Name != "Stomp" || giant.Faces[0].LinkType() != AdventureFace {
+		t.Errorf("wrong faces for Bonecrusher Giant: %+v", giant.Faces)
+	}
+	if cs := db.GetCard("Stomp"); len(cs) != 1 || cs[0].PrimaryFace() != giant {
+		t.Errorf("Stomp should be a face of Bonecrusher Giant, got %v", cs)
+	}
+
+	fireIce := db.GetCardBySetNumber("DMR", "215")
+	if fireIce == nil || fireIce.Name != "Fire // Ice" || fireIce.Cmc != 4 || len(fireIce.Faces) != 2 {
+		t.Fatalf("wrong Fire // Ice: %+v", fireIce)
+	}
+	if fireIce.TypeLine != "Instant" || fireIce.ManaCost.String() != "{1}{R}{1}{U}" {
+		t.Errorf("wrong Fire // Ice: %q %q", fireIce.TypeLine, fireIce.ManaCost)
+	}
+
+	maker := db.GetCardByID(72000)
+	if maker == nil || len(maker.Tokens) != 1 || maker.Tokens[0].Name != "Elf Warrior" || !maker.Tokens[0].IsToken {
+		t.Fatalf("wrong tokens for Elf Maker: %+v", maker)
+	}
+	if !maker.HasType("Creature") || len(maker.Supertypes) != 1 || maker.Rarity != MythicRarity || !maker.IsCraftable {
+		t.Errorf("wrong Elf Maker: %+v", *maker)
+	}
+	if cs := db.CardsCreatingToken("elf warrior"); len(cs) != 1 || cs[0] != maker {
+		t.Errorf("CardsCreatingToken(elf warrior) = %v, want Elf Maker", cs)
+	}
+
+	if forest := db.GetCardByID(68001); forest == nil || forest.Rarity != BasicLandRarity || forest.IsCraftable {
+		t.Errorf("wrong Forest: %+v", forest)
+	}
+
+	// Dominaria is DOM in Scryfall, and DAR in Arena.
+	if c := db.GetCardBySetNumber("DAR", "142"); c == nil || c.Name != "Shivan Fire" || c.Set != "DAR" {
+		t.Errorf("wrong card for DAR #142: %+v", c)
+	}
+	if cs := db.CardsInSet("dar"); len(cs) != 1 {
+		t.Errorf("wrong cards in DAR: %v", cs)
+	}
+
+	// The file is replaced by a newer one with the same name.
+	paths, err := FindMTGAResourceFiles(path)
+	if err != nil {
+		t.Fatalf("FindMTGAResourceFiles failed: %v", err)
+	}
+	before := paths.Hashes()["scryfall"]
+	if err := ioutil.WriteFile(path, []byte(scryfallTestData+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write scryfall file: %v", err)
+	}
+	if after := paths.Hashes()["scryfall"]; after == before {
+		t.Errorf("the scryfall hash should change when the file changes, got %q", after)
+	}
+}
+
+func TestScryfallBadFile(t *testing.T) {
+	for _, data := range []string{`{"object": "error"}`, `[{"arena_id": 1, "name": "X", "mana_cost": "{G"}]`, `[{"arena_id": 1`} {
+		f, err := ioutil.TempFile("", "carddb-scryfall-*.json")
+		if err != nil {
+			t.Fatalf("failed to create temp file: %v", err)
+		}
+		f.WriteString(data)
+		f.Close()
+		if _, err := CreateLibrary(f.Name()); err == nil {
+			t.Errorf("CreateLibrary(%q) should fail", data)
+		}
+		os.Remove(f.Name())
+	}
+}
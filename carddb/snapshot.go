@@ -0,0 +1,170 @@
+package carddb
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// snapshotVersion is the version of the snapshot format. It has to be bumped every time the
+// resources struct changes, so old snapshots are discarded.
+const snapshotVersion = 3
+
+const snapshotMagic = "mtgassistant-carddb"
+
+// snapshotFileName is the name of the snapshot file inside the cache directory.
+const snapshotFileName = "carddb.snapshot"
+
+// ErrStaleSnapshot is returned by ReadSnapshot when the snapshot was created from different
+// resource files, or by a different version of carddb.
+var ErrStaleSnapshot = errors.New("stale card database snapshot")
+
+type snapshotHeader struct {
+	Magic   string
+	Version int
+	Key     string
+}
+
+// WriteSnapshot serializes db into w, so it can be loaded later with ReadSnapshot. The key identifies
+// the resource files the database was created from. db has to be created by this package.
+func WriteSnapshot(w io.Writer, db CardDB, key string) error {
+	if l, ok := db.(*ReloadingLibrary); ok {
+		db = l.Current()
+	}
+	cdb, ok := db.(*cardDB)
+	if !ok {
+		return fmt.Errorf("can't create a snapshot of a %T", db)
+	}
+
+	zw := gzip.NewWriter(w)
+	enc := gob.NewEncoder(zw)
+	if err := enc.Encode(snapshotHeader{snapshotMagic, snapshotVersion, key}); err != nil {
+		return fmt.Errorf("failed to encode snapshot header: %v", err)
+	}
+	if err := enc.Encode(cdb.res); err != nil {
+		return fmt.Errorf("failed to encode card database: %v", err)
+	}
+	return zw.Close()
+}
+
+// ReadSnapshot loads a card database written by WriteSnapshot, with the card names in lang.
+// It returns ErrStaleSnapshot if the snapshot doesn't match the given key. An empty key accepts a
+// snapshot created from any resource files, as long as it was written by this version of carddb.
+func ReadSnapshot(r io.Reader, key string, lang string) (CardDB, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	defer zr.Close()
+
+	dec := gob.NewDecoder(zr)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot header: %v", err)
+	}
+	if header.Magic != snapshotMagic || header.Version != snapshotVersion || (key != "" && header.Key != key) {
+		return nil, ErrStaleSnapshot
+	}
+
+	var res resources
+	if err := dec.Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode card database: %v", err)
+	}
+	db, err := newCardDB(&res, lang)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// DefaultCacheDir returns the directory where the card database snapshots are stored by default,
+// or an empty string if the system doesn't have a cache directory.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mtgassistant")
+}
+
+// CreateCachedLibrary is like CreateLocalizedLibrary, but it keeps a snapshot of the database in cacheDir.
+// If the snapshot is up to date with the resource files in mtgDataPath, the database is loaded from it,
+// which is much faster than parsing the resource files. Otherwise, the database is created from the
+// resource files and the snapshot is updated. An empty cacheDir disables the cache.
+func CreateCachedLibrary(mtgDataPath string, cacheDir string, lang string) (CardDB, error) {
+	if cacheDir == "" {
+		return CreateLocalizedLibrary(mtgDataPath, lang)
+	}
+
+	paths, err := FindMTGAResourceFiles(mtgDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find mtga resource files: %v", err)
+	}
+	return CreateLibraryFromPaths(paths, cacheDir, lang)
+}
+
+// CreateLibraryFromPaths is like CreateCachedLibrary, but it uses the given resource files instead of
+// looking for them in the Data folder. An empty cacheDir disables the cache.
+func CreateLibraryFromPaths(paths ResourcePaths, cacheDir string, lang string) (CardDB, error) {
+	if cacheDir == "" {
+		res, err := parseResourcePaths(paths)
+		if err != nil {
+			return nil, err
+		}
+		return newCardDB(res, lang)
+	}
+
+	key := paths.key()
+	snapshotPath := filepath.Join(cacheDir, snapshotFileName)
+
+	if f, err := os.Open(snapshotPath); err == nil {
+		db, err := ReadSnapshot(f, key, lang)
+		f.Close()
+		if err == nil {
+			return db, nil
+		}
+		if err != ErrStaleSnapshot {
+			log.Printf("Ignoring card database snapshot %q: %v", snapshotPath, err)
+		}
+	}
+
+	res, err := parseResourcePaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	db, err := newCardDB(res, lang)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeSnapshotFile(snapshotPath, db, key); err != nil {
+		log.Printf("Failed to update card database snapshot: %v", err)
+	}
+	return db, nil
+}
+
+// writeSnapshotFile writes the snapshot to a temporary file and then renames it, so readers never
+// see a partially written snapshot.
+func writeSnapshotFile(path string, db CardDB, key string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), snapshotFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := WriteSnapshot(f, db, key); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
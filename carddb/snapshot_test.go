@@ -0,0 +1,86 @@
+package carddb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	db, err := NewLibrary(strings.NewReader(queryCardsJSON), strings.NewReader(queryTextsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, db, "key1"); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), "key2", "en-US"); err != ErrStaleSnapshot {
+		t.Errorf("ReadSnapshot with a different key. want ErrStaleSnapshot, got %v", err)
+	}
+
+	loaded, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), "key1", "en-US")
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+	db.ForEach(func(want Card) {
+		got := loaded.GetCardByID(want.ID)
+		if got == nil {
+			t.Errorf("card %d missing from snapshot", want.ID)
+			return
+		}
+		if got.Name != want.Name || got.TypeLine != want.TypeLine || got.RulesText != want.RulesText {
+			t.Errorf("card %d mismatch. want %+v, got %+v", want.ID, want, *got)
+		}
+	})
+}
+
+func TestCreateCachedLibrary(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "carddb-data")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+	cacheDir := filepath.Join(dataDir, "cache")
+
+	writeFile := func(name, contents string) {
+		if err := ioutil.WriteFile(filepath.Join(dataDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write %q: %v", name, err)
+		}
+	}
+	writeFile("data_cards_aaa.mtga", queryCardsJSON)
+	writeFile("data_loc_bbb.mtga", queryTextsJSON)
+
+	if _, err := CreateCachedLibrary(dataDir, cacheDir, "en-US"); err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, snapshotFileName)); err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+
+	// Corrupt the resource files, the library should still load from the snapshot.
+	writeFile("data_cards_aaa.mtga", "not json")
+	db, err := CreateCachedLibrary(dataDir, cacheDir, "en-US")
+	if err != nil {
+		t.Fatalf("failed to load library from snapshot: %v", err)
+	}
+	if c := db.GetCardByID(1); c == nil || c.Name != "Llanowar Elves" {
+		t.Errorf("wrong card loaded from snapshot: %v", c)
+	}
+
+	// A new cards file makes the snapshot stale.
+	os.Remove(filepath.Join(dataDir, "data_cards_aaa.mtga"))
+	writeFile("data_cards_ccc.mtga", `[{"grpid": 1, "titleId": 101}]`)
+	db, err = CreateCachedLibrary(dataDir, cacheDir, "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	if c := db.GetCardByID(1); c == nil || c.Name != "Mantle of Tides" {
+		t.Errorf("library was loaded from a stale snapshot: %v", c)
+	}
+}
@@ -0,0 +1,324 @@
+package carddb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Newer versions of MTG Arena ship the card data in a single SQLite database, stored in the Raw folder
+// next to the Data folder as Raw_CardDatabase_<hash>.mtga. It has the same information as the JSON
+// resource files, split in the Cards, Localizations_<lang>, Enums and Abilities tables.
+//
+// The SQLite driver needs cgo, so it is only linked in when building with -tags sqlite (see
+// sqlite_driver.go). Without it, the card database can't be read and the JSON files are used instead.
+
+// sqliteDriver is the name of the database/sql driver for the card database.
+const sqliteDriver = "sqlite3"
+
+var errNoSQLite = errors.New("SQLite support is not built in, rebuild with -tags sqlite (needs cgo)")
+
+// sqliteSupported returns whether the SQLite driver is linked in.
+func sqliteSupported() bool {
+	for _, d := range sql.Drivers() {
+		if d == sqliteDriver {
+			return true
+		}
+	}
+	return false
+}
+
+// sqliteFileURI returns the URI to open the SQLite database at path with the given mode (ro, rwc). The
+// path is made absolute, so that it doesn't end up as the authority of the URI (file://rel/x.db), and
+// Windows paths become file:///C:/... The path is escaped, so that characters like ? or # are not
+// taken as part of the URI.
+func sqliteFileURI(path, mode string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	u := url.URL{Scheme: "file", Path: p, RawQuery: "mode=" + mode}
+	return u.String(), nil
+}
+
+// localizationTableRegexp matches the per-language localization tables, like Localizations_enUS.
+var localizationTableRegexp = regexp.MustCompile(`^Localizations_([a-z]{2})([A-Z]{2})$`)
+
+// localizationColumnRegexp matches the language columns of the old single Localizations table.
+var localizationColumnRegexp = regexp.MustCompile(`^([a-z]{2})([A-Z]{2})$`)
+
+// parseCardDatabase reads all the resources from the SQLite card database at path.
+func parseCardDatabase(path string) (*resources, error) {
+	if !sqliteSupported() {
+		return nil, fmt.Errorf("failed to open card database: %v", errNoSQLite)
+	}
+	dsn, err := sqliteFileURI(path, "ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open card database: %v", err)
+	}
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open card database: %v", err)
+	}
+	defer db.Close()
+
+	res := &resources{
+		Enums:     make(enums),
+		Abilities: make(map[uint64]abilityJSON),
+	}
+	if res.Cards, err = readSQLiteCards(db); err != nil {
+		return nil, fmt.Errorf("failed to read cards: %v", err)
+	}
+	if res.Locs, err = readSQLiteLocalizations(db); err != nil {
+		return nil, fmt.Errorf("failed to read localizations: %v", err)
+	}
+	if err := readSQLiteTable(db, "Enums", func(row sqliteRow) error {
+		name := row.str("Type")
+		if res.Enums[name] == nil {
+			res.Enums[name] = make(map[uint64]uint64)
+		}
+		res.Enums[name][row.uint("Value")] = row.uint("LocId")
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read enums: %v", err)
+	}
+	if err := readSQLiteTable(db, "Abilities", func(row sqliteRow) error {
+		a := abilityJSON{
+			ID:          row.uint("Id"),
+			TextID:      row.uint("TextId"),
+			BaseID:      row.uint("BaseId"),
+			Category:    row.uint("Category"),
+			SubCategory: row.uint("SubCategory"),
+			AbilityWord: row.uint("AbilityWord"),
+		}
+		res.Abilities[a.ID] = a
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read abilities: %v", err)
+	}
+	return res, nil
+}
+
+func readSQLiteCards(db *sql.DB) ([]CardJSON, error) {
+	var cards []CardJSON
+	err := readSQLiteTable(db, "Cards", func(row sqliteRow) error {
+		abilities, err := row.abilityRefs("AbilityIds")
+		if err != nil {
+			return fmt.Errorf("card %s: %v", row.str("GrpId"), err)
+		}
+		hiddenAbilities, err := row.abilityRefs("HiddenAbilityIds")
+		if err != nil {
+			return fmt.Errorf("card %s: %v", row.str("GrpId"), err)
+		}
+		c := CardJSON{
+			ID:              row.uint("GrpId"),
+			TitleID:         row.uint("TitleId"),
+			ArtID:           row.uint("ArtId"),
+			FlavorID:        row.uint("FlavorTextId"),
+			ArtistCredit:    row.str("ArtistCredit"),
+			CollectorNumber: row.str("CollectorNumber"),
+			Set:             row.str("ExpansionCode"),
+			Rarity:          row.uint("Rarity"),
+			IsToken:         row.bool("IsToken"),
+			IsCollectible:   row.bool("IsPrimaryCard") && !row.bool("IsToken"),
+			IsCraftable:     row.bool("IsPrimaryCard") && !row.bool("IsToken") && row.uint("Rarity") > BasicLandRarity,
+			Power:           CardStat(row.str("Power")),
+			Toughness:       CardStat(row.str("Toughness")),
+			Colors:          row.uints("Colors"),
+			FrameColors:     row.uints("FrameColors"),
+			ColorIdentity:   row.uints("ColorIdentity"),
+			CastingCost:     row.str("OldSchoolManaText"),
+			Types:           row.uints("Types"),
+			Subtypes:        row.uints("Subtypes"),
+			Supertypes:      row.uints("Supertypes"),
+			CardTypeTextID:  row.uint("TypeTextId"),
+			SubtypeTextID:   row.uint("SubtypeTextId"),
+			Abilities:       abilities,
+			HiddenAbilities: hiddenAbilities,
+			LinkedFaceType:  row.uint("LinkedFaceType"),
+			LinkedFaces:     row.uints("LinkedFaceGrpIds"),
+			LinkedTokens:    row.uints("LinkedTokenGrpIds"),
+		}
+		if limit := row.uint("AltDeckLimit"); limit != 0 {
+			c.AltDeckLimit = &limit
+		}
+		if mc, err := ParseManaCost(c.CastingCost); err == nil {
+			c.Cmc = mc.ManaValue()
+		}
+		cards = append(cards, c)
+		return nil
+	})
+	return cards, err
+}
+
+func readSQLiteLocalizations(db *sql.DB) (localizations, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'Localizations%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var locs localizations
+	for _, table := range tables {
+		if m := localizationTableRegexp.FindStringSubmatch(table); m != nil {
+			loc := localization{IsoCode: m[1] + "-" + m[2], LangKey: strings.ToUpper(m[1]), Texts: make(map[uint64]string)}
+			if err := readSQLiteLocalizationTable(db, table, "Loc", loc.Texts); err != nil {
+				return nil, err
+			}
+			locs = append(locs, loc)
+			continue
+		}
+		if table != "Localizations" {
+			continue
+		}
+		// Older databases have a single table, with a column for each language.
+		columns, err := sqliteColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range columns {
+			m := localizationColumnRegexp.FindStringSubmatch(col)
+			if m == nil {
+				continue
+			}
+			loc := localization{IsoCode: m[1] + "-" + m[2], LangKey: strings.ToUpper(m[1]), Texts: make(map[uint64]string)}
+			if err := readSQLiteLocalizationTable(db, table, col, loc.Texts); err != nil {
+				return nil, err
+			}
+			locs = append(locs, loc)
+		}
+	}
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("no localization tables found")
+	}
+	return locs, nil
+}
+
+// readSQLiteLocalizationTable reads the texts in column of table into texts. A text can have several
+// versions with different formatting, the unformatted one (Formatted = 0) is preferred.
+func readSQLiteLocalizationTable(db *sql.DB, table string, column string, texts map[uint64]string) error {
+	formatted := make(map[uint64]uint64)
+	return readSQLiteTable(db, table, func(row sqliteRow) error {
+		id, f := row.uint("LocId"), row.uint("Formatted")
+		if prev, ok := formatted[id]; ok && prev <= f {
+			return nil
+		}
+		formatted[id] = f
+		texts[id] = row.str(column)
+		return nil
+	})
+}
+
+func sqliteColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(`SELECT * FROM "` + table + `" LIMIT 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// sqliteRow is a row of a table, indexed by column name. Missing columns and NULLs are empty strings.
+type sqliteRow map[string]string
+
+func (r sqliteRow) str(col string) string {
+	return r[col]
+}
+
+func (r sqliteRow) uint(col string) uint64 {
+	v, _ := strconv.ParseUint(r[col], 10, 64)
+	return v
+}
+
+func (r sqliteRow) bool(col string) bool {
+	v := r[col]
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+// uints parses a comma separated list of numbers.
+func (r sqliteRow) uints(col string) []uint64 {
+	var res []uint64
+	for _, s := range strings.Split(r[col], ",") {
+		if v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err == nil {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// abilityRefs parses a comma separated list of abilityId:textId pairs.
+func (r sqliteRow) abilityRefs(col string) ([]CardAbilityRef, error) {
+	var res []CardAbilityRef
+	for _, s := range strings.Split(r[col], ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, ":", 2)
+		id, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ability %q", s)
+		}
+		ref := CardAbilityRef{AbilityID: id}
+		if len(parts) == 2 {
+			if ref.TextID, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid ability %q", s)
+			}
+		}
+		res = append(res, ref)
+	}
+	return res, nil
+}
+
+// readSQLiteTable calls f for every row in table.
+func readSQLiteTable(db *sql.DB, table string, f func(sqliteRow) error) error {
+	rows, err := db.Query(`SELECT * FROM "` + table + `"`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		row := make(sqliteRow, len(columns))
+		for i, col := range columns {
+			row[col] = values[i].String
+		}
+		if err := f(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,8 @@
+//go:build sqlite
+// +build sqlite
+
+package carddb
+
+import (
+	_ "github.com/mattn/go-sqlite3" // sqlite3 driver for the Raw_CardDatabase file.
+)
@@ -0,0 +1,153 @@
+package carddb
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sqliteSchema = `
+CREATE TABLE Cards (GrpId INTEGER, TitleId INTEGER, ExpansionCode TEXT, CollectorNumber TEXT, Rarity INTEGER,
+	IsToken INTEGER, IsPrimaryCard INTEGER, Power TEXT, Toughness TEXT, Colors TEXT, ColorIdentity TEXT,
+	Types TEXT, Subtypes TEXT, Supertypes TEXT, OldSchoolManaText TEXT, AbilityIds TEXT,
+	LinkedFaceType INTEGER, LinkedFaceGrpIds TEXT, AltDeckLimit INTEGER);
+CREATE TABLE Localizations_enUS (LocId INTEGER, Formatted INTEGER, Loc TEXT);
+CREATE TABLE Localizations_esES (LocId INTEGER, Formatted INTEGER, Loc TEXT);
+CREATE TABLE Enums (Type TEXT, Value INTEGER, LocId INTEGER);
+CREATE TABLE Abilities (Id INTEGER, TextId INTEGER, BaseId INTEGER);
+
+INSERT INTO Cards VALUES (1, 101, 'M19', '314', 2, 0, 1, '1', '1', '5', '5', '2', '29', '', 'oG', '1005:301', 0, '', 250);
+INSERT INTO Cards VALUES (2, 102, 'M19', '1', 5, 0, 1, '', '', '', '', '5', '', '1', '', '', 0, '', NULL);
+INSERT INTO Localizations_enUS VALUES (101, 0, 'Llanowar Elves'), (102, 0, 'Forest'), (102, 1, '<i>Forest</i>'),
+	(201, 0, 'Creature'), (202, 0, 'Land'), (203, 0, 'Elf'), (204, 0, 'Basic'), (301, 0, '{oT}: Add {oG}.');
+INSERT INTO Localizations_esES VALUES (101, 0, 'Elfos de Llanowar'), (102, 0, 'Bosque');
+INSERT INTO Enums VALUES ('CardType', 2, 201), ('CardType', 5, 202), ('SubType', 29, 203), ('SuperType', 1, 204);
+INSERT INTO Abilities VALUES (1005, 301, 0);
+`
+
+// requireSQLite skips the test if the SQLite driver is not built in.
+func requireSQLite(t *testing.T) {
+	if !sqliteSupported() {
+		t.Skip("SQLite support is not built in, run the tests with -tags sqlite")
+	}
+}
+
+func writeTestCardDatabase(t *testing.T, path string) {
+	dsn, err := sqliteFileURI(path, "rwc")
+	if err != nil {
+		t.Fatalf("failed to get the URI of %q: %v", path, err)
+	}
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		t.Fatalf("failed to create card database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		t.Fatalf("failed to fill card database: %v", err)
+	}
+}
+
+func TestCardDatabase(t *testing.T) {
+	requireSQLite(t)
+	tmp, err := ioutil.TempDir("", "carddb-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	// The characters in the path must not be taken as part of the URI of the database.
+	dir := filepath.Join(tmp, "MTGA #1 100% ?")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("failed to create %q: %v", dir, err)
+	}
+	dataDir := filepath.Join(dir, "Data")
+	rawDir := filepath.Join(dir, "Raw")
+	for _, d := range []string{dataDir, rawDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("failed to create %q: %v", d, err)
+		}
+	}
+	writeTestCardDatabase(t, filepath.Join(rawDir, "Raw_CardDatabase_abc.mtga"))
+
+	paths, err := FindMTGAResourceFiles(dataDir)
+	if err != nil {
+		t.Fatalf("failed to find resource files: %v", err)
+	}
+	if got, want := paths.Hashes()["database"], "abc"; got != want {
+		t.Errorf("database hash mismatch. want %q, got %q", want, got)
+	}
+
+	db, err := CreateLibrary(dataDir)
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	cs := db.GetCard("Llanowar Elves")
+	if len(cs) != 1 {
+		t.Fatalf("GetCard(Llanowar Elves) failed. want 1 card, got %v", cs)
+	}
+	elves := cs[0]
+	if elves.TypeLine != "Creature — Elf" || elves.Power != "1" || elves.Cmc != 1 || elves.ColorString() != "G" {
+		t.Errorf("wrong Llanowar Elves: %+v", *elves)
+	}
+	if elves.AltDeckLimit == nil || *elves.AltDeckLimit != 250 {
+		t.Errorf("wrong altDeckLimit. want 250, got %v", elves.AltDeckLimit)
+	}
+	if elves.RulesText != "{oT}: Add {oG}." {
+		t.Errorf("wrong rules text: %q", elves.RulesText)
+	}
+	if c := db.GetCardBySetNumber("m19", "1"); c == nil || c.Name != "Forest" || c.TypeLine != "Basic Land" || c.AltDeckLimit != nil {
+		t.Errorf("wrong card for M19 #1: %+v", c)
+	}
+
+	db, err = CreateLocalizedLibrary(dataDir, "es-ES")
+	if err != nil {
+		t.Fatalf("failed to create spanish library: %v", err)
+	}
+	if c := db.GetCardByID(1); c == nil || c.Name != "Elfos de Llanowar" {
+		t.Errorf("wrong spanish card: %+v", c)
+	}
+}
+
+func TestSQLiteFileURI(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	got, err := sqliteFileURI(filepath.Join("rel", "x #1.db"), "ro")
+	if err != nil {
+		t.Fatalf("sqliteFileURI failed: %v", err)
+	}
+	// A relative path must not end up as the authority of the URI.
+	if !strings.HasPrefix(got, "file:///") || !strings.HasSuffix(got, "/rel/x%20%231.db?mode=ro") {
+		t.Errorf("wrong URI for a relative path in %q: %q", wd, got)
+	}
+}
+
+func TestCardDatabaseRelativePath(t *testing.T) {
+	requireSQLite(t)
+	tmp, err := ioutil.TempDir("", "carddb-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "Raw_CardDatabase_abc.mtga")
+	writeTestCardDatabase(t, path)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		t.Fatalf("failed to get relative path to %q: %v", path, err)
+	}
+	res, err := parseCardDatabase(rel)
+	if err != nil {
+		t.Fatalf("failed to open card database at %q: %v", rel, err)
+	}
+	if len(res.Cards) != 2 {
+		t.Errorf("wrong number of cards. want 2, got %d", len(res.Cards))
+	}
+}
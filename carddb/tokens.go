@@ -0,0 +1,59 @@
+package carddb
+
+// linkTokens fills in the Tokens of every card in cards, and returns the cards that create each token,
+// by token ID.
+func linkTokens(cards []Card, byID map[uint64]*Card) map[uint64][]*Card {
+	creators := make(map[uint64][]*Card)
+	for i := range cards {
+		card := &cards[i]
+		for _, id := range card.LinkedTokens {
+			token, ok := byID[id]
+			if !ok || containsCard(card.Tokens, token) {
+				continue
+			}
+			card.Tokens = append(card.Tokens, token)
+			creators[id] = append(creators[id], card)
+		}
+	}
+	return creators
+}
+
+// AllTokens returns the tokens created by the card and by its other faces.
+func (c *Card) AllTokens() []*Card {
+	tokens := append([]*Card(nil), c.Tokens...)
+	for _, f := range c.Faces {
+		for _, t := range f.Tokens {
+			if !containsCard(tokens, t) {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func (db *cardDB) TokenCreators(tokenID uint64) []*Card {
+	var res []*Card
+	for _, c := range db.creators[tokenID] {
+		if p := c.PrimaryFace(); !containsCard(res, p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (db *cardDB) CardsCreatingToken(name string) []*Card {
+	name = normalizeName(name)
+	var res []*Card
+	for i := range db.cardList {
+		token := &db.cardList[i]
+		if !token.IsToken || normalizeName(token.Name) != name {
+			continue
+		}
+		for _, c := range db.TokenCreators(token.ID) {
+			if !containsCard(res, c) {
+				res = append(res, c)
+			}
+		}
+	}
+	return res
+}
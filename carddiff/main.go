@@ -0,0 +1,142 @@
+// program carddiff compares the card databases of two versions of "Magic The Gathering - Arena", and prints
+// the cards that were added, removed or changed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+)
+
+var (
+	oldPath    = flag.String("old", "", "Data folder or card database snapshot of the old version")
+	newPath    = flag.String("new", "", "Data folder or card database snapshot of the new version")
+	language   = flag.String("lang", "en-US", "Language for the card names (e.g. es-ES, pt-BR)")
+	jsonOutput = flag.Bool("json", false, "Print the differences as JSON")
+)
+
+// loadLibrary loads the card database in path, which can be a Data folder or a snapshot file.
+func loadLibrary(path string, lang string) (carddb.CardDB, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return carddb.CreateLocalizedLibrary(path, lang)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return carddb.ReadSnapshot(f, "", lang)
+}
+
+type jsonCard struct {
+	ID              uint64 `json:"id"`
+	TitleID         uint64 `json:"titleId"`
+	Name            string `json:"name"`
+	Set             string `json:"set"`
+	CollectorNumber string `json:"collectorNumber"`
+}
+
+type jsonDiff struct {
+	Added        []jsonCard           `json:"added"`
+	Removed      []jsonCard           `json:"removed"`
+	Changed      []carddb.CardChange  `json:"changed"`
+	Renamed      []carddb.TitleRename `json:"renamed"`
+	NewPrintings []jsonCard           `json:"newPrintings"`
+	Rebalanced   []jsonCard           `json:"rebalanced"`
+}
+
+func toJSONCards(cs []*carddb.Card) []jsonCard {
+	res := []jsonCard{}
+	for _, c := range cs {
+		res = append(res, jsonCard{c.ID, c.TitleID, c.Name, c.Set, c.CollectorNumber})
+	}
+	return res
+}
+
+func printJSON(w io.Writer, d *carddb.Diff) error {
+	jd := jsonDiff{
+		Added:        toJSONCards(d.Added),
+		Removed:      toJSONCards(d.Removed),
+		Changed:      d.Changed,
+		Renamed:      d.Renamed,
+		NewPrintings: toJSONCards(d.NewPrintings),
+		Rebalanced:   toJSONCards(d.Rebalanced),
+	}
+	if jd.Changed == nil {
+		jd.Changed = []carddb.CardChange{}
+	}
+	if jd.Renamed == nil {
+		jd.Renamed = []carddb.TitleRename{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jd)
+}
+
+func printText(w io.Writer, d *carddb.Diff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+	printCards := func(title string, cs []*carddb.Card) {
+		if len(cs) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(cs))
+		for _, c := range cs {
+			fmt.Fprintf(w, "  %d %s (%s) %s\n", c.ID, c.Name, c.Set, c.CollectorNumber)
+		}
+	}
+	printCards("Added", d.Added)
+	printCards("Removed", d.Removed)
+	printCards("New printings", d.NewPrintings)
+	printCards("Rebalanced", d.Rebalanced)
+	if len(d.Renamed) > 0 {
+		fmt.Fprintf(w, "Renamed (%d):\n", len(d.Renamed))
+		for _, r := range d.Renamed {
+			fmt.Fprintf(w, "  %d %q -> %q\n", r.TitleID, r.Old, r.New)
+		}
+	}
+	if len(d.Changed) > 0 {
+		fmt.Fprintf(w, "Changed (%d):\n", len(d.Changed))
+		for _, c := range d.Changed {
+			fmt.Fprintf(w, "  %d %s\n", c.ID, c.Name)
+			for _, f := range c.Fields {
+				fmt.Fprintf(w, "    %s: %q -> %q\n", f.Field, f.Old, f.New)
+			}
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *oldPath == "" || *newPath == "" {
+		log.Fatal("both -old and -new are required")
+	}
+	oldDB, err := loadLibrary(*oldPath, *language)
+	if err != nil {
+		log.Fatalf("failed to load old card database: %v", err)
+	}
+	newDB, err := loadLibrary(*newPath, *language)
+	if err != nil {
+		log.Fatalf("failed to load new card database: %v", err)
+	}
+
+	d := carddb.DiffLibraries(oldDB, newDB)
+	if *jsonOutput {
+		if err := printJSON(os.Stdout, d); err != nil {
+			log.Fatalf("failed to print differences: %v", err)
+		}
+		return
+	}
+	printText(os.Stdout, d)
+}
@@ -12,9 +12,13 @@ import (
 )
 
 var (
-	mtgOutputLog = flag.String("log_file", `${USERPROFILE}\AppData\LocalLow\Wizards Of The Coast\MTGA\output_log.txt`, "Filepath of the MTG Arena Output Log, typically stored in an MTG folder inside C:\\Users")
+	mtgOutputLog = flag.String("log_file", collectionfinder.DefaultLogFile(), "Filepath of the MTG Arena log (Player.log, or output_log.txt in old versions), typically stored in an MTG folder inside C:\\Users")
 	mtgDataPath  = flag.String("mtg_data", `C:\Program Files (x86)\Wizards of the Coast\MTGA\MTGA_Data\Downloads\Data`, "Path to the Downloads\\Data folder inside the MTG Arena Install Directory")
+	cacheDir     = flag.String("cache_dir", carddb.DefaultCacheDir(), "Directory for the card database cache. Empty disables the cache.")
 	inventory    = flag.Bool("inventory", false, "Also output user inventory")
+	language     = flag.String("lang", "en-US", "Language for the card names (e.g. es-ES, pt-BR)")
+	diagnostics  = flag.Bool("diagnostics", false, "Print the messages in the log that couldn't be decoded.")
+	strict       = flag.Bool("strict", false, "Fail if a message in the log can't be decoded, instead of skipping it.")
 )
 
 func main() {
@@ -25,32 +29,48 @@ func main() {
 		log.Fatalf("failed to open log file: %v", err)
 	}
 	defer f.Close()
-	cardLists, err := collectionfinder.FindCollection(f)
+	mtgaLog, err := collectionfinder.ParseLogWithOptions(f, collectionfinder.ParseOptions{Strict: *strict})
 	if err != nil {
 		log.Fatalf("failed to parse mtga logs: %v", err)
 	}
+	if len(mtgaLog.Diagnostics) > 0 {
+		log.Printf("Skipped %d messages that couldn't be decoded", len(mtgaLog.Diagnostics))
+	}
+	if *diagnostics {
+		for _, d := range mtgaLog.Diagnostics {
+			log.Println(d)
+		}
+	}
+	cardLists := mtgaLog.Collections
 	if len(cardLists) < 1 {
 		log.Fatal("no decks found in the mtg logs. make sure to enable logs in the Arena app.")
 	}
 	cardList := cardLists[len(cardLists)-1]
 
 	log.Println("Parsing MTG Data Files...")
-	db, err := carddb.CreateLibrary(*mtgDataPath)
+	db, err := carddb.CreateCachedLibrary(*mtgDataPath, *cacheDir, *language)
 	if err != nil {
 		log.Fatalf("createLibrary failed: %v", err)
 	}
 
+	// Both faces of a card might be in the collection, but they are a single card.
+	cardList = carddb.CollapseFaces(db, cardList)
+	unknown := 0
 	for id, count := range cardList {
 		card := db.GetCardByID(id)
+		if card == nil {
+			// The card database might be older than the log, or not have every card (e.g. Scryfall).
+			unknown++
+			continue
+		}
 		fmt.Printf("%d %s (%s) %s\n", count, card.Name, card.Set, card.CollectorNumber)
 	}
+	if unknown > 0 {
+		log.Printf("Skipped %d cards that are not in the card database", unknown)
+	}
 
 	if *inventory {
-		f.Seek(0, 0) // Rewind file.
-		inventories, err := collectionfinder.FindInventory(f)
-		if err != nil {
-			log.Fatalf("failed to parse mtga logs: %v", err)
-		}
+		inventories := mtgaLog.Inventories
 		if len(inventories) == 0 {
 			log.Fatalf("No inventory found")
 		}
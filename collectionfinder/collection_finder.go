@@ -1,19 +1,20 @@
 // Package collectionfinder parses the Magic The Gathering: Logs, returning user decks.
+//
+// The Parser reads the logs in a single pass and returns the messages it recognizes as events. The Find
+// functions are built on top of it, and ParseLog returns everything they do with a single read.
+//
+// Messages that can't be decoded, usually because an update of the game changed their format, are
+// skipped. The Parser returns them as a DiagnosticEvent, ParseLog in Log.Diagnostics, and the
+// Find*WithOptions functions as a separate list. Use ParseOptions.Strict (or Parser.Strict) to fail
+// instead.
 package collectionfinder
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
+	"os"
 )
 
-const playerCollectionMessage string = "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3"
-const playerInventoryMessage string = "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory"
-const playerInventoryUpdatedMessage string = "[UnityCrossThreadLogger]<== Inventory.Updated"
-
 // PlayerInventory represents the inventory of a player.
 type PlayerInventory struct {
 	PlayerID   string `json:"playerId"`
@@ -23,9 +24,16 @@ type PlayerInventory struct {
 	WcMythic   int    `json:"wcMythic"`
 }
 
-type arenaMessage struct {
-	ID      int             `json:"id"`
-	Payload json.RawMessage `json:"payload"`
+// startHookMsg is the response to StartHook in Player.log, which has the inventory of the player.
+type startHookMsg struct {
+	InventoryInfo *inventoryInfoMsg `json:"InventoryInfo"`
+}
+
+type inventoryInfoMsg struct {
+	WildCardCommons   int `json:"WildCardCommons"`
+	WildCardUnCommons int `json:"WildCardUnCommons"`
+	WildCardRares     int `json:"WildCardRares"`
+	WildCardMythics   int `json:"WildCardMythics"`
 }
 
 type cardListMsg map[string]uint32
@@ -60,113 +68,186 @@ type BoosterContents struct {
 	CardIds           []uint64
 }
 
-// FindBoosters returns the list of all opened boosters in the MTG Arena Logs.
-func FindBoosters(mtgalogs io.Reader) ([]BoosterContents, error) {
-	inventoryUpdates, err := findMessages(mtgalogs, playerInventoryUpdatedMessage)
-	if err != nil {
-		return nil, err
-	}
+// InventoryUpdate is a change in the inventory of the player.
+type InventoryUpdate struct {
+	// Context is what caused the update, e.g. "Booster.Open".
+	Context string
+	// Contents are the wildcards and cards added to the inventory.
+	Contents BoosterContents
+}
 
-	res := make([]BoosterContents, 0)
-	for _, updateRaw := range inventoryUpdates {
-		var update inventoryUpdateJSON
-		json.Unmarshal([]byte(updateRaw), &update)
-		if update.Context != "Booster.Open" {
-			continue
+// DefaultLogFile returns the path to the MTG Arena log, with environment variables to be expanded with
+// os.ExpandEnv. Current versions of the game write Player.log, and old versions wrote output_log.txt,
+// which is returned if it is the only one that exists.
+func DefaultLogFile() string {
+	const dir = `${USERPROFILE}\AppData\LocalLow\Wizards Of The Coast\MTGA\`
+	if _, err := os.Stat(os.ExpandEnv(dir + "Player.log")); os.IsNotExist(err) {
+		if _, err := os.Stat(os.ExpandEnv(dir + "output_log.txt")); err == nil {
+			return dir + "output_log.txt"
 		}
+	}
+	return dir + "Player.log"
+}
 
-		var contents BoosterContents
-		for _, u := range update.Updates {
-			contents.CommonWildcards += u.Delta.WcCommonDelta
-			contents.UncommonWildcards += u.Delta.WcUncommonDelta
-			contents.RareWildcards += u.Delta.WcRareDelta
-			contents.MythicWildcards += u.Delta.WcMythicDelta
-			for _, c := range u.AetherizedCards {
-				contents.CardIds = append(contents.CardIds, c.GrpID)
+// findEvents returns the events of the given types in the logs, and the messages of those types that
+// were skipped because they couldn't be decoded. In strict mode, only those messages make it fail.
+func findEvents(mtgalogs io.Reader, opts ParseOptions, types ...EventType) ([]Event, []Diagnostic, error) {
+	wanted := func(typ EventType) bool {
+		for _, t := range types {
+			if t == typ {
+				return true
 			}
 		}
-		res = append(res, contents)
+		return false
 	}
-	return res, nil
-}
-
-// FindInventory returns a list of all the inventories that appear in the MTG Logs
-func FindInventory(mtgalogs io.Reader) ([]PlayerInventory, error) {
-	inventories, err := findMessages(mtgalogs, playerInventoryMessage)
-	if err != nil {
-		return nil, err
+	p := NewParser(mtgalogs)
+	res := make([]Event, 0)
+	var diags []Diagnostic
+	for {
+		ev, err := p.Next()
+		if err == io.EOF {
+			return res, diags, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case wanted(ev.Type):
+			res = append(res, ev)
+		case ev.Type == DiagnosticEvent && wanted(ev.Diagnostic.Type):
+			if opts.Strict {
+				return nil, nil, fmt.Errorf("failed to decode arena message at %v", ev.Diagnostic)
+			}
+			diags = append(diags, *ev.Diagnostic)
+		}
 	}
+}
 
-	res := make([]PlayerInventory, len(inventories), len(inventories))
+// FindBoosters returns the list of all opened boosters in the MTG Arena Logs. The messages that can't
+// be decoded are skipped, use FindBoostersWithOptions to get them.
+func FindBoosters(mtgalogs io.Reader) ([]BoosterContents, error) {
+	res, _, err := FindBoostersWithOptions(mtgalogs, ParseOptions{})
+	return res, err
+}
 
-	for i, inventory := range inventories {
-		json.Unmarshal([]byte(inventory), &res[i])
+// FindBoostersWithOptions is like FindBoosters, with options. It also returns the messages that were
+// skipped because they couldn't be decoded.
+func FindBoostersWithOptions(mtgalogs io.Reader, opts ParseOptions) ([]BoosterContents, []Diagnostic, error) {
+	updates, diags, err := findEvents(mtgalogs, opts, InventoryUpdateEvent)
+	if err != nil {
+		return nil, nil, err
 	}
-	return res, nil
+	return boosters(updates), diags, nil
 }
 
-func findMessages(mtgalogs io.Reader, prefix string) ([]json.RawMessage, error) {
-	reader := bufio.NewReader(mtgalogs)
-	res := make([]json.RawMessage, 0)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, fmt.Errorf("failed to read line %v", err)
-		}
-		if !strings.HasPrefix(line, prefix) {
+func boosters(updates []Event) []BoosterContents {
+	res := make([]BoosterContents, 0)
+	for _, ev := range updates {
+		if ev.InventoryUpdate.Context != "Booster.Open" {
 			continue
 		}
-		// This line might contain the entire json payload, or just one part.
-		// Look for the first appearence of `{`
-		// Example: `[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3 {"id":232,...`
-		var jsonReader io.Reader
-		ind := strings.Index(line, "{")
-		if ind != -1 {
-			// This line contains a `{` at position ind.
-			// Create a substring with that and put into a reader.
-			sr := strings.NewReader(line[ind:])
-			jsonReader = io.MultiReader(sr, reader)
-		} else {
-			jsonReader = reader
-		}
-
-		decoder := json.NewDecoder(jsonReader)
-		var msg arenaMessage
-		if err := decoder.Decode(&msg); err != nil {
-			return nil, fmt.Errorf("failed to decode arena message: %v", err)
-		}
-		res = append(res, msg.Payload)
+		res = append(res, ev.InventoryUpdate.Contents)
 	}
+	return res
+}
 
-	return res, nil
+// FindInventory returns a list of all the inventories that appear in the MTG Logs. The messages that
+// can't be decoded are skipped, use FindInventoryWithOptions to get them.
+func FindInventory(mtgalogs io.Reader) ([]PlayerInventory, error) {
+	res, _, err := FindInventoryWithOptions(mtgalogs, ParseOptions{})
+	return res, err
 }
 
-// FindCollection returns the list of user collections from the MTGA Logs.
-func FindCollection(mtgalogs io.Reader) ([]map[uint64]uint32, error) {
-	collections, err := findMessages(mtgalogs, playerCollectionMessage)
+// FindInventoryWithOptions is like FindInventory, with options. It also returns the messages that were
+// skipped because they couldn't be decoded.
+func FindInventoryWithOptions(mtgalogs io.Reader, opts ParseOptions) ([]PlayerInventory, []Diagnostic, error) {
+	inventories, diags, err := findEvents(mtgalogs, opts, InventoryEvent)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	res := make([]PlayerInventory, len(inventories), len(inventories))
+	for i, ev := range inventories {
+		res[i] = *ev.Inventory
+	}
+	return res, diags, nil
+}
 
+// FindCollection returns the list of user collections from the MTGA Logs. The messages that can't be
+// decoded are skipped, use FindCollectionWithOptions to get them.
+func FindCollection(mtgalogs io.Reader) ([]map[uint64]uint32, error) {
+	res, _, err := FindCollectionWithOptions(mtgalogs, ParseOptions{})
+	return res, err
+}
+
+// FindCollectionWithOptions is like FindCollection, with options. It also returns the messages that
+// were skipped because they couldn't be decoded.
+func FindCollectionWithOptions(mtgalogs io.Reader, opts ParseOptions) ([]map[uint64]uint32, []Diagnostic, error) {
+	collections, diags, err := findEvents(mtgalogs, opts, CollectionEvent)
+	if err != nil {
+		return nil, nil, err
+	}
 	cardLists := make([]map[uint64]uint32, 0)
+	for _, ev := range collections {
+		cardLists = append(cardLists, ev.Collection)
+	}
+	return cardLists, diags, nil
+}
 
-	for _, c := range collections {
-		var playerCards cardListMsg
-		json.Unmarshal([]byte(c), &playerCards)
+// Log has the information found in the MTG Arena Logs, in the order it appears in them.
+type Log struct {
+	Collections []map[uint64]uint32
+	Inventories []PlayerInventory
+	Boosters    []BoosterContents
+	// Decks are the decks saved by the player, as of the end of the logs.
+	Decks []Deck
+	// Matches are the matches played by the player.
+	Matches []MatchRecord
+	// Diagnostics are the messages that were skipped because they couldn't be decoded.
+	Diagnostics []Diagnostic
+}
 
-		cards := make(map[uint64]uint32)
-		for txtID, count := range playerCards {
-			id, err := strconv.Atoi(txtID)
-			if err != nil {
-				return nil, fmt.Errorf("found non-numeric ID %q: %v", txtID, err)
-			}
-			cards[uint64(id)] = count
+// ParseOptions change how ParseLogWithOptions reads the logs.
+type ParseOptions struct {
+	// Strict makes parsing fail at the first message that can't be decoded, instead of adding it to
+	// the Diagnostics.
+	Strict bool
+}
+
+// ParseLog reads the MTG Arena Logs in a single pass, and returns everything the Find functions do.
+func ParseLog(mtgalogs io.Reader) (*Log, error) {
+	return ParseLogWithOptions(mtgalogs, ParseOptions{})
+}
+
+// ParseLogWithOptions is like ParseLog, with options.
+func ParseLogWithOptions(mtgalogs io.Reader, opts ParseOptions) (*Log, error) {
+	p := NewParser(mtgalogs)
+	p.Strict = opts.Strict
+	var matches matchTracker
+	l := &Log{
+		Collections: make([]map[uint64]uint32, 0),
+		Inventories: make([]PlayerInventory, 0),
+		Boosters:    make([]BoosterContents, 0),
+	}
+	for {
+		ev, err := p.Next()
+		if err == io.EOF {
+			l.Decks = matches.decks.list()
+			l.Matches = matches.list()
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		matches.add(ev)
+		switch ev.Type {
+		case CollectionEvent:
+			l.Collections = append(l.Collections, ev.Collection)
+		case InventoryEvent:
+			l.Inventories = append(l.Inventories, *ev.Inventory)
+		case InventoryUpdateEvent:
+			l.Boosters = append(l.Boosters, boosters([]Event{ev})...)
+		case DiagnosticEvent:
+			l.Diagnostics = append(l.Diagnostics, *ev.Diagnostic)
 		}
-		cardLists = append(cardLists, cards)
 	}
-
-	return cardLists, nil
 }
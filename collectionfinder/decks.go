@@ -0,0 +1,216 @@
+package collectionfinder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Deck is a deck saved by the player. The cards are counts by grpid.
+type Deck struct {
+	ID     string
+	Name   string
+	Format string
+
+	MainDeck  map[uint64]uint32
+	Sideboard map[uint64]uint32
+	Commander map[uint64]uint32
+	Companion map[uint64]uint32
+}
+
+// deckCardsMsg is a list of cards in a deck. Old versions of the game write it as a flat list of grpids
+// and counts ([68000, 4, 68001, 2]), and current versions as a list of objects
+// ([{"cardId": 68000, "quantity": 4}, ...]).
+type deckCardsMsg map[uint64]uint32
+
+func (d *deckCardsMsg) UnmarshalJSON(b []byte) error {
+	*d = make(deckCardsMsg)
+	var flat []uint64
+	if err := json.Unmarshal(b, &flat); err == nil {
+		if len(flat)%2 != 0 {
+			return fmt.Errorf("odd number of values in card list: %d", len(flat))
+		}
+		for i := 0; i < len(flat); i += 2 {
+			(*d)[flat[i]] += uint32(flat[i+1])
+		}
+		return nil
+	}
+	var cards []struct {
+		CardID   uint64 `json:"cardId"`
+		Quantity uint32 `json:"quantity"`
+	}
+	if err := json.Unmarshal(b, &cards); err != nil {
+		return err
+	}
+	for _, c := range cards {
+		(*d)[c.CardID] += c.Quantity
+	}
+	return nil
+}
+
+// legacyDeckMsg is a deck in the Deck.*V3 messages of old versions of the game.
+type legacyDeckMsg struct {
+	ID             string       `json:"id"`
+	Name           string       `json:"name"`
+	Format         string       `json:"format"`
+	MainDeck       deckCardsMsg `json:"mainDeck"`
+	Sideboard      deckCardsMsg `json:"sideboard"`
+	CommandZone    deckCardsMsg `json:"commandZone"`
+	CompanionGRPID uint64       `json:"companionGRPId"`
+}
+
+func (m *legacyDeckMsg) deck() Deck {
+	d := Deck{ID: m.ID, Name: m.Name, Format: m.Format, MainDeck: m.MainDeck, Sideboard: m.Sideboard, Commander: m.CommandZone}
+	if m.CompanionGRPID != 0 {
+		d.Companion = map[uint64]uint32{m.CompanionGRPID: 1}
+	}
+	return d
+}
+
+// deckMsg is the contents of a deck in Player.log.
+type deckMsg struct {
+	MainDeck    deckCardsMsg `json:"MainDeck"`
+	Sideboard   deckCardsMsg `json:"Sideboard"`
+	CommandZone deckCardsMsg `json:"CommandZone"`
+	Companions  deckCardsMsg `json:"Companions"`
+}
+
+// deckSummaryMsg has the name and attributes of a deck in Player.log.
+type deckSummaryMsg struct {
+	DeckID     string `json:"DeckId"`
+	Name       string `json:"Name"`
+	Attributes []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"Attributes"`
+}
+
+func (m *deckMsg) deck(summary deckSummaryMsg) Deck {
+	d := Deck{ID: summary.DeckID, Name: summary.Name, MainDeck: m.MainDeck, Sideboard: m.Sideboard, Commander: m.CommandZone, Companion: m.Companions}
+	for _, a := range summary.Attributes {
+		if a.Name == "Format" {
+			d.Format = a.Value
+		}
+	}
+	return d
+}
+
+// deckUpsertMsg is the request sent when a deck is created or updated in Player.log.
+type deckUpsertMsg struct {
+	Summary *deckSummaryMsg `json:"Summary"`
+	Deck    *deckMsg        `json:"Deck"`
+}
+
+// startHookDecksMsg are the decks in the response to StartHook.
+type startHookDecksMsg struct {
+	Decks           map[string]deckMsg `json:"Decks"`
+	DeckSummariesV2 []deckSummaryMsg   `json:"DeckSummariesV2"`
+}
+
+// decodeDecks returns the decks in the payload of ev. It returns nil for a StartHook without decks,
+// which is not a list of decks, while an empty list means that the player has no decks.
+func decodeDecks(ev *Event) ([]Deck, error) {
+	decks := make([]Deck, 0)
+	switch {
+	case ev.Method == "StartHook":
+		var msg startHookDecksMsg
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		if msg.Decks == nil && msg.DeckSummariesV2 == nil {
+			return nil, nil
+		}
+		for _, s := range msg.DeckSummariesV2 {
+			if m, ok := msg.Decks[s.DeckID]; ok {
+				decks = append(decks, m.deck(s))
+			}
+		}
+	case ev.Method == "DeckUpsertDeckV2":
+		var msg deckUpsertMsg
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		if msg.Summary == nil || msg.Deck == nil {
+			return nil, fmt.Errorf("missing field %q or %q", "Summary", "Deck")
+		}
+		decks = append(decks, msg.Deck.deck(*msg.Summary))
+	case ev.Type == DeckListsEvent:
+		var msgs []legacyDeckMsg
+		if err := json.Unmarshal(ev.Payload, &msgs); err != nil {
+			return nil, err
+		}
+		for i := range msgs {
+			decks = append(decks, msgs[i].deck())
+		}
+	default:
+		var msg legacyDeckMsg
+		if err := checkFields(ev.Payload, "id", "mainDeck"); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		decks = append(decks, msg.deck())
+	}
+	return decks, nil
+}
+
+// deckList keeps the current decks of the player, as they are updated by the events in the logs.
+type deckList struct {
+	decks map[string]Deck
+	order []string // IDs of the decks, in the order they were first seen.
+}
+
+func (l *deckList) add(ev Event) {
+	if l.decks == nil {
+		l.decks = make(map[string]Deck)
+	}
+	if ev.Type == DeckListsEvent || ev.Type == InventoryEvent {
+		// The whole list of decks, the ones that are not in it were deleted.
+		if ev.Decks == nil {
+			return
+		}
+		l.decks = make(map[string]Deck)
+		l.order = nil
+	}
+	for _, d := range ev.Decks {
+		if _, ok := l.decks[d.ID]; !ok {
+			l.order = append(l.order, d.ID)
+		}
+		l.decks[d.ID] = d
+	}
+}
+
+func (l *deckList) list() []Deck {
+	res := make([]Deck, 0, len(l.order))
+	for _, id := range l.order {
+		res = append(res, l.decks[id])
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// deckEvents are the events that have decks.
+var deckEvents = []EventType{InventoryEvent, DeckListsEvent, DeckUpdateEvent}
+
+// FindDecks returns the decks saved by the player, as of the end of the logs, sorted by name. The
+// messages that can't be decoded are skipped, use FindDecksWithOptions to get them.
+func FindDecks(mtgalogs io.Reader) ([]Deck, error) {
+	res, _, err := FindDecksWithOptions(mtgalogs, ParseOptions{})
+	return res, err
+}
+
+// FindDecksWithOptions is like FindDecks, with options. It also returns the messages that were
+// skipped because they couldn't be decoded.
+func FindDecksWithOptions(mtgalogs io.Reader, opts ParseOptions) ([]Deck, []Diagnostic, error) {
+	events, diags, err := findEvents(mtgalogs, opts, deckEvents...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var decks deckList
+	for _, ev := range events {
+		decks.add(ev)
+	}
+	return decks.list(), diags, nil
+}
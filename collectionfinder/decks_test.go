@@ -0,0 +1,94 @@
+package collectionfinder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const legacyDecksLog = `[UnityCrossThreadLogger]<== Deck.GetDeckListsV3(10)
+{
+  "id": 10,
+  "payload": [
+    {"id": "d1", "name": "Mono Green", "format": "Standard", "mainDeck": [68000, 4, 68001, 20], "sideboard": [68002, 2]},
+    {"id": "d2", "name": "Brawl", "format": "Brawl", "mainDeck": [68001, 40], "commandZone": [68003, 1], "companionGRPId": 68004}
+  ]
+}
+[UnityCrossThreadLogger]<== Deck.UpdateDeckV3(11) {"id": 11, "payload": {"id": "d1", "name": "Mono Green", "format": "Standard", "mainDeck": [68000, 3, 68001, 21], "sideboard": []}}
+[UnityCrossThreadLogger]<== Deck.CreateDeckV3(12) {"id": 12, "payload": {"id": "d3", "name": "Azorius", "format": "Historic", "mainDeck": [68005, 4]}}
+`
+
+const modernDecksLog = `[UnityCrossThreadLogger]==> StartHook {"id":"s1","request":"{}"}
+<== StartHook(s1)
+{"InventoryInfo":{"WildCardCommons":1,"WildCardUnCommons":1,"WildCardRares":1,"WildCardMythics":1},"DeckSummariesV2":[{"DeckId":"d1","Name":"Mono Green","Attributes":[{"name":"Version","value":"3"},{"name":"Format","value":"Standard"}]}],"Decks":{"d1":{"MainDeck":[{"cardId":68000,"quantity":4},{"cardId":68001,"quantity":20}],"Sideboard":[],"CommandZone":[],"Companions":[{"cardId":68004,"quantity":1}]}}}
+[UnityCrossThreadLogger]==> DeckUpsertDeckV2 {"id":"u1","request":"{\"Summary\":{\"DeckId\":\"d2\",\"Name\":\"Azorius\",\"Attributes\":[{\"name\":\"Format\",\"value\":\"Historic\"}]},\"Deck\":{\"MainDeck\":[{\"cardId\":68005,\"quantity\":4}],\"Sideboard\":[{\"cardId\":68002,\"quantity\":1}]}}"}
+`
+
+func TestFindDecks(t *testing.T) {
+	decks, err := FindDecks(strings.NewReader(legacyDecksLog))
+	if err != nil {
+		t.Fatalf("FindDecks failed: %v", err)
+	}
+	want := []Deck{
+		{ID: "d3", Name: "Azorius", Format: "Historic", MainDeck: map[uint64]uint32{68005: 4}},
+		{ID: "d2", Name: "Brawl", Format: "Brawl", MainDeck: map[uint64]uint32{68001: 40}, Commander: map[uint64]uint32{68003: 1}, Companion: map[uint64]uint32{68004: 1}},
+		{ID: "d1", Name: "Mono Green", Format: "Standard", MainDeck: map[uint64]uint32{68000: 3, 68001: 21}, Sideboard: map[uint64]uint32{}},
+	}
+	if !reflect.DeepEqual(decks, want) {
+		t.Errorf("wrong legacy decks.\nwant %+v\ngot  %+v", want, decks)
+	}
+
+	decks, err = FindDecks(strings.NewReader(modernDecksLog))
+	if err != nil {
+		t.Fatalf("FindDecks failed: %v", err)
+	}
+	want = []Deck{
+		{ID: "d2", Name: "Azorius", Format: "Historic", MainDeck: map[uint64]uint32{68005: 4}, Sideboard: map[uint64]uint32{68002: 1}},
+		{ID: "d1", Name: "Mono Green", Format: "Standard", MainDeck: map[uint64]uint32{68000: 4, 68001: 20}, Sideboard: map[uint64]uint32{}, Commander: map[uint64]uint32{}, Companion: map[uint64]uint32{68004: 1}},
+	}
+	if !reflect.DeepEqual(decks, want) {
+		t.Errorf("wrong modern decks.\nwant %+v\ngot  %+v", want, decks)
+	}
+
+	l, err := ParseLog(strings.NewReader(modernDecksLog))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if !reflect.DeepEqual(l.Decks, want) || len(l.Inventories) != 1 {
+		t.Errorf("wrong ParseLog decks: %+v", l)
+	}
+
+	// A StartHook without decks doesn't remove the decks seen before it.
+	logs := modernDecksLog + `[UnityCrossThreadLogger]==> StartHook {"id":"s2","request":"{}"}
+<== StartHook(s2)
+{"InventoryInfo":{"WildCardCommons":2,"WildCardUnCommons":2,"WildCardRares":2,"WildCardMythics":2}}
+`
+	decks, err = FindDecks(strings.NewReader(logs))
+	if err != nil {
+		t.Fatalf("FindDecks failed: %v", err)
+	}
+	if !reflect.DeepEqual(decks, want) {
+		t.Errorf("wrong decks after a StartHook without decks.\nwant %+v\ngot  %+v", want, decks)
+	}
+}
+
+func TestBadDecks(t *testing.T) {
+	logs := `[UnityCrossThreadLogger]<== Deck.GetDeckListsV3(10) {"id": 10, "payload": [{"id": "d1", "name": "Odd", "mainDeck": [68000, 4, 68001]}]}
+[UnityCrossThreadLogger]<== Deck.UpdateDeckV3(11) {"id": 11, "payload": {"id": "d1", "name": "Renamed"}}
+`
+	l, err := ParseLog(strings.NewReader(logs))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(l.Decks) != 0 || len(l.Diagnostics) != 2 {
+		t.Errorf("the bad deck lists should be skipped: %+v", l)
+	}
+
+	decks, diags, err := FindDecksWithOptions(strings.NewReader(logs), ParseOptions{})
+	if err != nil || len(decks) != 0 || len(diags) != 2 || diags[0].Line != 1 || diags[1].Line != 2 {
+		t.Errorf("wrong FindDecksWithOptions result: %v, %v, %v", decks, diags, err)
+	}
+	if _, _, err := FindDecksWithOptions(strings.NewReader(logs), ParseOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("strict FindDecksWithOptions should fail at line 1, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package collectionfinder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// DraftPick is a card picked in a draft.
+type DraftPick struct {
+	// DraftID identifies the draft. Bot drafts don't have an ID in the logs, and it is the name of the
+	// event instead.
+	DraftID string
+	// CardIDs are the grpids of the picked cards. It is a single card, except in the formats where
+	// several cards are picked at once.
+	CardIDs []uint64
+	// PackNumber and PickNumber are as they are written in the logs.
+	PackNumber int
+	PickNumber int
+}
+
+// logNumber is a number in the logs, that some messages write as a string ("68000").
+type logNumber uint64
+
+func (n *logNumber) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		*n = logNumber(v)
+		return nil
+	}
+	var v uint64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*n = logNumber(v)
+	return nil
+}
+
+// legacyDraftPickMsg are the params of Draft.MakePick in old versions of the game.
+type legacyDraftPickMsg struct {
+	DraftID    string    `json:"draftId"`
+	CardID     logNumber `json:"cardId"`
+	PackNumber logNumber `json:"packNumber"`
+	PickNumber logNumber `json:"pickNumber"`
+}
+
+// botDraftPickMsg is the request of BotDraft_DraftPick in Player.log. Older versions have a single
+// CardId.
+type botDraftPickMsg struct {
+	EventName string `json:"EventName"`
+	PickInfo  *struct {
+		CardID     logNumber   `json:"CardId"`
+		CardIDs    []logNumber `json:"CardIds"`
+		PackNumber logNumber   `json:"PackNumber"`
+		PickNumber logNumber   `json:"PickNumber"`
+	} `json:"PickInfo"`
+}
+
+// playerDraftPickMsg is the request of EventPlayerDraftMakePick in Player.log.
+type playerDraftPickMsg struct {
+	DraftID string      `json:"DraftId"`
+	GrpIDs  []logNumber `json:"GrpIds"`
+	Pack    logNumber   `json:"Pack"`
+	Pick    logNumber   `json:"Pick"`
+}
+
+func cardIDs(ns ...logNumber) []uint64 {
+	var ids []uint64
+	for _, n := range ns {
+		if n != 0 {
+			ids = append(ids, uint64(n))
+		}
+	}
+	return ids
+}
+
+// decodeDraftPick returns the pick in the payload of a DraftPickEvent.
+func decodeDraftPick(ev *Event) (*DraftPick, error) {
+	var pick DraftPick
+	switch ev.Method {
+	case "BotDraft_DraftPick":
+		var msg botDraftPickMsg
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		if msg.PickInfo == nil {
+			return nil, fmt.Errorf("missing field %q", "PickInfo")
+		}
+		info := msg.PickInfo
+		pick = DraftPick{DraftID: msg.EventName, CardIDs: cardIDs(info.CardIDs...), PackNumber: int(info.PackNumber), PickNumber: int(info.PickNumber)}
+		if len(info.CardIDs) == 0 {
+			pick.CardIDs = cardIDs(info.CardID)
+		}
+	case "EventPlayerDraftMakePick":
+		var msg playerDraftPickMsg
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		pick = DraftPick{DraftID: msg.DraftID, CardIDs: cardIDs(msg.GrpIDs...), PackNumber: int(msg.Pack), PickNumber: int(msg.Pick)}
+	default:
+		var msg legacyDraftPickMsg
+		if err := json.Unmarshal(ev.Payload, &msg); err != nil {
+			return nil, err
+		}
+		pick = DraftPick{DraftID: msg.DraftID, CardIDs: cardIDs(msg.CardID), PackNumber: int(msg.PackNumber), PickNumber: int(msg.PickNumber)}
+	}
+	if len(pick.CardIDs) == 0 {
+		return nil, errors.New("no picked card")
+	}
+	return &pick, nil
+}
@@ -0,0 +1,146 @@
+package collectionfinder
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// followInterval is how often Follow checks the log file for new data.
+var followInterval = time.Second
+
+// followHeadSize is how many bytes from the start of the log are kept to tell whether the file was
+// rewritten.
+const followHeadSize = 4096
+
+// followReader reads a log file that is still being written. At the end of the file, it waits for
+// more data instead of returning io.EOF, until the file is truncated or replaced.
+type followReader struct {
+	ctx  context.Context
+	path string
+	f    *os.File
+	// pos is the number of bytes read from f.
+	pos int64
+	// reset is set when the file was truncated or replaced, and the reader has to be reopened.
+	reset bool
+	// head are the first bytes of the file, and modTime its modification time when it was last checked.
+	head    []byte
+	modTime time.Time
+}
+
+func (fr *followReader) open() error {
+	f, err := os.Open(fr.path)
+	if err != nil {
+		return err
+	}
+	if fr.f != nil {
+		fr.f.Close()
+	}
+	fr.f, fr.pos, fr.reset, fr.head = f, 0, false, nil
+	if fi, err := f.Stat(); err == nil {
+		fr.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+// changed returns whether the file at path was truncated, or replaced by a new one. When the game
+// starts, it moves Player.log to Player-prev.log and writes a new Player.log. The file might have been
+// truncated and written past the read position since the last check, so its size is not enough: the
+// first bytes have to be the same ones that were read, and the modification time can't go back.
+func (fr *followReader) changed() bool {
+	fi, err := os.Stat(fr.path)
+	if err != nil {
+		// The new file might not be created yet.
+		return false
+	}
+	cur, err := fr.f.Stat()
+	if err != nil {
+		return true
+	}
+	if !os.SameFile(fi, cur) || cur.Size() < fr.pos || cur.ModTime().Before(fr.modTime) {
+		return true
+	}
+	fr.modTime = cur.ModTime()
+	head := make([]byte, len(fr.head))
+	n, _ := fr.f.ReadAt(head, 0)
+	return !bytes.Equal(head[:n], fr.head)
+}
+
+func (fr *followReader) Read(b []byte) (int, error) {
+	for {
+		n, err := fr.f.Read(b)
+		if missing := followHeadSize - len(fr.head); missing > 0 && fr.pos == int64(len(fr.head)) {
+			if missing > n {
+				missing = n
+			}
+			fr.head = append(fr.head, b[:missing]...)
+		}
+		fr.pos += int64(n)
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-fr.ctx.Done():
+			return 0, fr.ctx.Err()
+		case <-time.After(followInterval):
+		}
+		// Check before reading the new data, it is not a continuation of what was read if the file
+		// changed.
+		if fr.changed() {
+			fr.reset = true
+			return 0, io.EOF
+		}
+	}
+}
+
+// Follow parses the log file at path like Stream, but at the end of the file it keeps waiting for the
+// game to write more messages, until ctx is done. When the game restarts, the log file is truncated or
+// replaced, and Follow continues from the beginning of the new file (Line and Offset of the events
+// are relative to the file they are in). A message cut by the restart is returned as a
+// DiagnosticEvent.
+//
+// The events channel is closed when ctx is done or the logs can't be parsed, and the error is sent to
+// the error channel.
+func Follow(ctx context.Context, path string) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	fr := &followReader{ctx: ctx, path: path}
+	if err := fr.open(); err != nil {
+		close(events)
+		errc <- err
+		return events, errc
+	}
+	go func() {
+		defer close(events)
+		defer func() { fr.f.Close() }()
+		p := NewParser(fr)
+		for {
+			ev, err := p.Next()
+			if err != nil && fr.reset {
+				if err := fr.open(); err != nil {
+					errc <- err
+					return
+				}
+				p = NewParser(fr)
+				continue
+			}
+			if ctx.Err() != nil {
+				errc <- ctx.Err()
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return events, errc
+}
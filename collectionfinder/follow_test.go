@@ -0,0 +1,111 @@
+package collectionfinder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path string, data string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %q: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("events channel closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestFollow(t *testing.T) {
+	defer func(d time.Duration) { followInterval = d }(followInterval)
+	followInterval = 5 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "collectionfinder-follow")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Player.log")
+
+	appendFile(t, path, "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 1, \"wcMythic\": 0}}\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := Follow(ctx, path)
+	if ev := nextEvent(t, events); ev.Inventory.WcRare != 1 || ev.Line != 1 {
+		t.Errorf("wrong first event: %+v", ev)
+	}
+
+	// A message written in several steps is returned once it is complete.
+	appendFile(t, path, "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(2)\n{\"id\": 2, ")
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, path, "\"payload\": {\"68000\": 3}}\n")
+	if ev := nextEvent(t, events); ev.Type != CollectionEvent || ev.Collection[68000] != 3 || ev.Line != 2 {
+		t.Errorf("wrong collection event: %+v", ev)
+	}
+
+	// The game restarts and truncates the log.
+	if err := ioutil.WriteFile(path, []byte("[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 2, \"wcMythic\": 0}}\n"), 0644); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+	if ev := nextEvent(t, events); ev.Inventory.WcRare != 2 || ev.Line != 1 {
+		t.Errorf("wrong event after truncation: %+v", ev)
+	}
+
+	// The game restarts and writes more than what was read before the next check.
+	rewritten := "[UnityCrossThreadLogger]1/10/2023 8:00:00 PM\n" +
+		"[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 5, \"wcMythic\": 0}}\n" +
+		"[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 6, \"wcMythic\": 0}}\n"
+	if err := ioutil.WriteFile(path, []byte(rewritten), 0644); err != nil {
+		t.Fatalf("failed to rewrite log: %v", err)
+	}
+	for i, want := range []int{5, 6} {
+		if ev := nextEvent(t, events); ev.Inventory.WcRare != want || ev.Line != i+2 {
+			t.Errorf("wrong event after rewrite. want %d rares at line %d, got %+v", want, i+2, ev)
+		}
+	}
+
+	// The game restarts and moves the log to Player-prev.log. The rest of the old log is read first.
+	appendFile(t, path, "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 3, \"wcMythic\": 0}}\n")
+	if err := os.Rename(path, filepath.Join(dir, "Player-prev.log")); err != nil {
+		t.Fatalf("failed to rotate log: %v", err)
+	}
+	appendFile(t, path, "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(1) {\"id\": 1, \"payload\": {\"wcCommon\": 0, \"wcUncommon\": 0, \"wcRare\": 4, \"wcMythic\": 0}}\n")
+	for _, want := range []int{3, 4} {
+		if ev := nextEvent(t, events); ev.Inventory.WcRare != want {
+			t.Errorf("wrong event after rotation. want %d rares, got %+v", want, ev)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Follow should stop when the context is canceled, got %v", err)
+	}
+}
+
+func TestFollowMissingFile(t *testing.T) {
+	events, errc := Follow(context.Background(), filepath.Join(os.TempDir(), "collectionfinder-missing", "Player.log"))
+	for range events {
+	}
+	if err := <-errc; err == nil {
+		t.Errorf("Follow should fail for a missing file")
+	}
+}
@@ -0,0 +1,351 @@
+package collectionfinder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MatchRecord is a match played by the player.
+type MatchRecord struct {
+	MatchID string
+	// EventName is the event where the match was played, e.g. "Ladder" or "QuickDraft_ONE_20230110".
+	EventName string
+	// Format is "Constructed" or "Limited", and BestOf the number of games of the match (1 or 3). They
+	// are empty if the game messages of the match are not in the logs.
+	Format string
+	BestOf int
+	// Opponent is the name of the opponent, and OpponentRank its rank (e.g. "Gold 2"), if it is known.
+	Opponent     string
+	OpponentRank string
+	Games        []GameResult
+	// Won is whether the player won the match, and Drawn whether it ended in a draw.
+	Won   bool
+	Drawn bool
+	Start time.Time
+	End   time.Time
+	// Deck are the cards in the main deck used in the first game, by grpid. DeckName is the name of the
+	// deck with the same main deck among the ones saved when the match started, if there is one.
+	Deck     map[uint64]uint32
+	DeckName string
+}
+
+// Duration returns how long the match took. It is zero if the logs don't have timestamps.
+func (m *MatchRecord) Duration() time.Duration {
+	if m.Start.IsZero() || m.End.IsZero() {
+		return 0
+	}
+	return m.End.Sub(m.Start)
+}
+
+// OnPlay returns whether the player went first in the first game of the match. ok is false if it is
+// not in the logs.
+func (m *MatchRecord) OnPlay() (onPlay bool, ok bool) {
+	if len(m.Games) == 0 || !m.Games[0].PlayKnown {
+		return false, false
+	}
+	return m.Games[0].OnPlay, true
+}
+
+// GameResult is the result of a game of a match.
+type GameResult struct {
+	// Won is whether the player won the game, and Drawn whether it ended in a draw.
+	Won   bool
+	Drawn bool
+	// OnPlay is whether the player went first. It is only meaningful if PlayKnown is set: the first
+	// turn of the game might not be in the logs.
+	OnPlay    bool
+	PlayKnown bool
+}
+
+const (
+	matchPlayingState   = "MatchGameRoomStateType_Playing"
+	matchCompletedState = "MatchGameRoomStateType_MatchCompleted"
+	gameScope           = "MatchScope_Game"
+	matchScope          = "MatchScope_Match"
+	drawResult          = "ResultType_Draw"
+)
+
+// matchMsg has the fields used by FindMatches of the messages of a match: Event.MatchCreated in old
+// versions of the game, MatchGameRoomStateChangedEvent, and GreToClientEvent.
+type matchMsg struct {
+	// Event.MatchCreated
+	MatchID                        string  `json:"matchId"`
+	EventID                        string  `json:"eventId"`
+	OpponentScreenName             string  `json:"opponentScreenName"`
+	OpponentRankingClass           string  `json:"opponentRankingClass"`
+	OpponentRankingTier            int     `json:"opponentRankingTier"`
+	OpponentMythicPercentile       float64 `json:"opponentMythicPercentile"`
+	OpponentMythicLeaderboardPlace int     `json:"opponentMythicLeaderboardPlace"`
+
+	StateChanged *struct {
+		GameRoomInfo gameRoomInfoMsg `json:"gameRoomInfo"`
+	} `json:"matchGameRoomStateChangedEvent"`
+
+	GreToClient *struct {
+		Messages []greMessageMsg `json:"greToClientMessages"`
+	} `json:"greToClientEvent"`
+}
+
+type gameRoomInfoMsg struct {
+	GameRoomConfig struct {
+		EventID         string              `json:"eventId"`
+		MatchID         string              `json:"matchId"`
+		ReservedPlayers []reservedPlayerMsg `json:"reservedPlayers"`
+	} `json:"gameRoomConfig"`
+	StateType        string `json:"stateType"`
+	FinalMatchResult *struct {
+		MatchID    string `json:"matchId"`
+		ResultList []struct {
+			Scope         string `json:"scope"`
+			Result        string `json:"result"`
+			WinningTeamID int    `json:"winningTeamId"`
+		} `json:"resultList"`
+	} `json:"finalMatchResult"`
+}
+
+type reservedPlayerMsg struct {
+	UserID       string `json:"userId"`
+	PlayerName   string `json:"playerName"`
+	SystemSeatID int    `json:"systemSeatId"`
+	TeamID       int    `json:"teamId"`
+}
+
+type greMessageMsg struct {
+	Type          string `json:"type"`
+	SystemSeatIDs []int  `json:"systemSeatIds"`
+	ConnectResp   *struct {
+		DeckMessage struct {
+			DeckCards []uint64 `json:"deckCards"`
+		} `json:"deckMessage"`
+	} `json:"connectResp"`
+	GameStateMessage *struct {
+		GameInfo *struct {
+			MatchID           string `json:"matchID"`
+			GameNumber        int    `json:"gameNumber"`
+			SuperFormat       string `json:"superFormat"`
+			MatchWinCondition string `json:"matchWinCondition"`
+		} `json:"gameInfo"`
+		TurnInfo *struct {
+			TurnNumber   int `json:"turnNumber"`
+			ActivePlayer int `json:"activePlayer"`
+		} `json:"turnInfo"`
+	} `json:"gameStateMessage"`
+}
+
+// decodeMatchMessage decodes the payload of a MatchEvent, MatchStateEvent or GameMessagesEvent.
+func decodeMatchMessage(ev *Event) (*matchMsg, error) {
+	var m matchMsg
+	if err := json.Unmarshal(ev.Payload, &m); err != nil {
+		return nil, err
+	}
+	switch {
+	case ev.Type == MatchEvent && m.MatchID == "":
+		return nil, fmt.Errorf("missing field %q", "matchId")
+	case ev.Type == MatchStateEvent && m.StateChanged == nil:
+		return nil, fmt.Errorf("missing field %q", "matchGameRoomStateChangedEvent")
+	case ev.Type == GameMessagesEvent && m.GreToClient == nil:
+		return nil, fmt.Errorf("missing field %q", "greToClientEvent")
+	}
+	return &m, nil
+}
+
+// matchTracker builds the MatchRecords from the events in the logs.
+type matchTracker struct {
+	matches []MatchRecord
+	// current is the match being played, nil between matches.
+	current *MatchRecord
+	// seat is the seat of the player in the current match, 0 if it is not known yet.
+	seat    int
+	players []reservedPlayerMsg
+	// firstSeat has the seat that went first in each game of the current match.
+	firstSeat map[int]int
+	// created are the Event.MatchCreated messages, by match ID.
+	created map[string]*matchMsg
+	decks   deckList
+	// startDecks are the decks saved when the current match started.
+	startDecks []Deck
+}
+
+func (t *matchTracker) start(matchID string, ev Event) *MatchRecord {
+	if t.current != nil && t.current.MatchID == matchID {
+		return t.current
+	}
+	t.current = &MatchRecord{MatchID: matchID, Start: ev.Time}
+	t.seat = 0
+	t.players = nil
+	t.firstSeat = make(map[int]int)
+	t.startDecks = t.decks.list()
+	if c, ok := t.created[matchID]; ok {
+		t.current.EventName = c.EventID
+		t.current.Opponent = c.OpponentScreenName
+		t.current.OpponentRank = rankString(c)
+		delete(t.created, matchID)
+	}
+	return t.current
+}
+
+// rankString returns the rank of the opponent in a MatchCreated message.
+func rankString(c *matchMsg) string {
+	switch {
+	case c.OpponentRankingClass == "" || c.OpponentRankingClass == "None":
+		return ""
+	case c.OpponentMythicLeaderboardPlace > 0:
+		return fmt.Sprintf("%s #%d", c.OpponentRankingClass, c.OpponentMythicLeaderboardPlace)
+	case c.OpponentMythicPercentile > 0:
+		return fmt.Sprintf("%s %.0f%%", c.OpponentRankingClass, c.OpponentMythicPercentile)
+	case c.OpponentRankingTier > 0:
+		return fmt.Sprintf("%s %d", c.OpponentRankingClass, c.OpponentRankingTier)
+	}
+	return c.OpponentRankingClass
+}
+
+func (t *matchTracker) add(ev Event) {
+	t.decks.add(ev)
+	m := ev.match
+	if m == nil {
+		return
+	}
+	switch ev.Type {
+	case MatchEvent:
+		if t.created == nil {
+			t.created = make(map[string]*matchMsg)
+		}
+		t.created[m.MatchID] = m
+	case MatchStateEvent:
+		info := &m.StateChanged.GameRoomInfo
+		config := &info.GameRoomConfig
+		matchID := config.MatchID
+		if info.FinalMatchResult != nil && matchID == "" {
+			matchID = info.FinalMatchResult.MatchID
+		}
+		if matchID == "" || (info.StateType != matchPlayingState && info.StateType != matchCompletedState) {
+			return
+		}
+		rec := t.start(matchID, ev)
+		if config.EventID != "" {
+			rec.EventName = config.EventID
+		}
+		if len(config.ReservedPlayers) > 0 {
+			t.players = config.ReservedPlayers
+		}
+		if info.StateType == matchCompletedState && info.FinalMatchResult != nil {
+			t.finish(ev, info)
+		}
+	case GameMessagesEvent:
+		if t.current == nil {
+			return
+		}
+		for _, msg := range m.GreToClient.Messages {
+			t.addGameMessage(&msg)
+		}
+	}
+}
+
+func (t *matchTracker) addGameMessage(msg *greMessageMsg) {
+	rec := t.current
+	if len(msg.SystemSeatIDs) == 1 && t.seat == 0 {
+		// The messages are sent to the seat of the player.
+		t.seat = msg.SystemSeatIDs[0]
+	}
+	if msg.ConnectResp != nil && rec.Deck == nil {
+		rec.Deck = make(map[uint64]uint32)
+		for _, id := range msg.ConnectResp.DeckMessage.DeckCards {
+			rec.Deck[id]++
+		}
+	}
+	gs := msg.GameStateMessage
+	if gs == nil {
+		return
+	}
+	game := 1
+	if gi := gs.GameInfo; gi != nil {
+		if gi.GameNumber > 0 {
+			game = gi.GameNumber
+		}
+		if gi.SuperFormat != "" {
+			rec.Format = strings.TrimPrefix(gi.SuperFormat, "SuperFormat_")
+		}
+		switch gi.MatchWinCondition {
+		case "MatchWinCondition_SingleElimination":
+			rec.BestOf = 1
+		case "MatchWinCondition_Best2of3":
+			rec.BestOf = 3
+		}
+	}
+	if ti := gs.TurnInfo; ti != nil && ti.TurnNumber == 1 && ti.ActivePlayer != 0 {
+		if _, ok := t.firstSeat[game]; !ok {
+			t.firstSeat[game] = ti.ActivePlayer
+		}
+	}
+}
+
+// finish completes the current match with its final result.
+func (t *matchTracker) finish(ev Event, info *gameRoomInfoMsg) {
+	rec := t.current
+	rec.End = ev.Time
+	team := 0
+	for _, p := range t.players {
+		if p.SystemSeatID == t.seat {
+			team = p.TeamID
+		} else if t.seat != 0 && rec.Opponent == "" {
+			rec.Opponent = p.PlayerName
+		}
+	}
+	for _, r := range info.FinalMatchResult.ResultList {
+		drawn := r.Result == drawResult || r.WinningTeamID == 0
+		won := !drawn && team != 0 && r.WinningTeamID == team
+		switch r.Scope {
+		case gameScope:
+			first := t.firstSeat[len(rec.Games)+1]
+			g := GameResult{Won: won, Drawn: drawn, PlayKnown: first != 0 && t.seat != 0}
+			g.OnPlay = g.PlayKnown && first == t.seat
+			rec.Games = append(rec.Games, g)
+		case matchScope:
+			rec.Won, rec.Drawn = won, drawn
+		}
+	}
+	if rec.Deck != nil {
+		for _, d := range t.startDecks {
+			if reflect.DeepEqual(d.MainDeck, rec.Deck) {
+				rec.DeckName = d.Name
+				break
+			}
+		}
+	}
+	t.matches = append(t.matches, *rec)
+	t.current = nil
+}
+
+func (t *matchTracker) list() []MatchRecord {
+	if t.matches == nil {
+		return make([]MatchRecord, 0)
+	}
+	return t.matches
+}
+
+// FindMatches returns the matches played by the player that finished in the logs. The messages that
+// can't be decoded are skipped, use FindMatchesWithOptions to get them.
+func FindMatches(mtgalogs io.Reader) ([]MatchRecord, error) {
+	res, _, err := FindMatchesWithOptions(mtgalogs, ParseOptions{})
+	return res, err
+}
+
+// FindMatchesWithOptions is like FindMatches, with options. It also returns the messages that were
+// skipped because they couldn't be decoded, including the ones with decks, which are used to name the
+// deck of each match.
+func FindMatchesWithOptions(mtgalogs io.Reader, opts ParseOptions) ([]MatchRecord, []Diagnostic, error) {
+	types := append([]EventType{MatchEvent, MatchStateEvent, GameMessagesEvent}, deckEvents...)
+	events, diags, err := findEvents(mtgalogs, opts, types...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var t matchTracker
+	for _, ev := range events {
+		t.add(ev)
+	}
+	return t.list(), diags, nil
+}
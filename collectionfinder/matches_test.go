@@ -0,0 +1,167 @@
+package collectionfinder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// legacyMatchLog is a best of three match in the output_log.txt of old versions of the game. The player
+// is in seat 2, and wins the match 2-1.
+const legacyMatchLog = `[UnityCrossThreadLogger]<== Deck.GetDeckListsV3(10) {"id": 10, "payload": [{"id": "d1", "name": "Mono Green", "format": "Standard", "mainDeck": [68000, 2, 68001, 1]}]}
+[UnityCrossThreadLogger]1/10/2020 8:40:00 PM
+<== Event.MatchCreated {"matchId": "m1", "eventId": "Traditional_Ladder", "opponentScreenName": "Opponent#12345", "opponentRankingClass": "Gold", "opponentRankingTier": 2}
+[UnityCrossThreadLogger]1/10/2020 8:40:05 PM: Match to P1: MatchGameRoomStateChangedEvent
+{
+  "transactionId": "t1",
+  "matchGameRoomStateChangedEvent": {
+    "gameRoomInfo": {
+      "gameRoomConfig": {
+        "eventId": "Traditional_Ladder",
+        "matchId": "m1",
+        "reservedPlayers": [
+          {"userId": "P2", "playerName": "Opponent", "systemSeatId": 1, "teamId": 1},
+          {"userId": "P1", "playerName": "Player", "systemSeatId": 2, "teamId": 2}
+        ]
+      },
+      "stateType": "MatchGameRoomStateType_Playing"
+    }
+  }
+}
+[UnityCrossThreadLogger]1/10/2020 8:40:06 PM: Match to P1: GreToClientEvent
+{"transactionId": "t2", "greToClientEvent": {"greToClientMessages": [{"type": "GREMessageType_ConnectResp", "systemSeatIds": [2], "connectResp": {"deckMessage": {"deckCards": [68000, 68001, 68000]}}}]}}
+[UnityCrossThreadLogger]1/10/2020 8:40:07 PM: Match to P1: GreToClientEvent
+{"transactionId": "t3", "greToClientEvent": {"greToClientMessages": [{"type": "GREMessageType_GameStateMessage", "systemSeatIds": [2], "gameStateMessage": {"gameInfo": {"matchID": "m1", "gameNumber": 1, "superFormat": "SuperFormat_Constructed", "matchWinCondition": "MatchWinCondition_Best2of3"}, "turnInfo": {"turnNumber": 1, "activePlayer": 1}}}]}}
+[UnityCrossThreadLogger]1/10/2020 8:50:00 PM: Match to P1: GreToClientEvent
+{"transactionId": "t4", "greToClientEvent": {"greToClientMessages": [{"type": "GREMessageType_GameStateMessage", "systemSeatIds": [2], "gameStateMessage": {"gameInfo": {"matchID": "m1", "gameNumber": 2}, "turnInfo": {"turnNumber": 1, "activePlayer": 2}}}]}}
+[UnityCrossThreadLogger]1/10/2020 9:00:00 PM: Match to P1: GreToClientEvent
+{"transactionId": "t5", "greToClientEvent": {"greToClientMessages": [{"type": "GREMessageType_GameStateMessage", "systemSeatIds": [2], "gameStateMessage": {"gameInfo": {"matchID": "m1", "gameNumber": 3}, "turnInfo": {"turnNumber": 1, "activePlayer": 2}}}]}}
+[UnityCrossThreadLogger]1/10/2020 9:10:05 PM: Match to P1: MatchGameRoomStateChangedEvent
+{"transactionId": "t6", "matchGameRoomStateChangedEvent": {"gameRoomInfo": {"gameRoomConfig": {"eventId": "Traditional_Ladder", "matchId": "m1"}, "stateType": "MatchGameRoomStateType_MatchCompleted", "finalMatchResult": {"matchId": "m1", "resultList": [{"scope": "MatchScope_Game", "result": "ResultType_WinLoss", "winningTeamId": 1}, {"scope": "MatchScope_Game", "result": "ResultType_WinLoss", "winningTeamId": 2}, {"scope": "MatchScope_Game", "result": "ResultType_WinLoss", "winningTeamId": 2}, {"scope": "MatchScope_Match", "result": "ResultType_WinLoss", "winningTeamId": 2}]}}}}
+`
+
+// modernMatchLog is a best of one match in Player.log, where the messages of the match server don't
+// have a header. The player is in seat 1, and loses.
+const modernMatchLog = `[UnityCrossThreadLogger]2023-01-10 20:40:00
+{ "transactionId": "t1", "timestamp": "1673383200000", "matchGameRoomStateChangedEvent": { "gameRoomInfo": { "gameRoomConfig": { "eventId": "QuickDraft_ONE_20230110", "matchId": "m2", "reservedPlayers": [ { "userId": "P1", "playerName": "Player", "systemSeatId": 1, "teamId": 1 }, { "userId": "P2", "playerName": "Opponent", "systemSeatId": 2, "teamId": 2 } ] }, "stateType": "MatchGameRoomStateType_Playing" } } }
+[UnityCrossThreadLogger]2023-01-10 20:40:01
+{ "transactionId": "t2", "timestamp": "1673383201000", "greToClientEvent": { "greToClientMessages": [ { "type": "GREMessageType_ConnectResp", "systemSeatIds": [ 1 ], "connectResp": { "deckMessage": { "deckCards": [ 68005 ] } } }, { "type": "GREMessageType_GameStateMessage", "systemSeatIds": [ 1 ], "gameStateMessage": { "gameInfo": { "matchID": "m2", "gameNumber": 1, "superFormat": "SuperFormat_Limited", "matchWinCondition": "MatchWinCondition_SingleElimination" }, "turnInfo": { "turnNumber": 1, "activePlayer": 1 } } } ] } }
+[UnityCrossThreadLogger]2023-01-10 20:55:01
+{ "transactionId": "t3", "timestamp": "1673384101000", "matchGameRoomStateChangedEvent": { "gameRoomInfo": { "gameRoomConfig": { "eventId": "QuickDraft_ONE_20230110", "matchId": "m2", "reservedPlayers": [ { "userId": "P1", "playerName": "Player", "systemSeatId": 1, "teamId": 1 }, { "userId": "P2", "playerName": "Opponent", "systemSeatId": 2, "teamId": 2 } ] }, "stateType": "MatchGameRoomStateType_MatchCompleted", "finalMatchResult": { "matchId": "m2", "resultList": [ { "scope": "MatchScope_Game", "result": "ResultType_WinLoss", "winningTeamId": 2 }, { "scope": "MatchScope_Match", "result": "ResultType_WinLoss", "winningTeamId": 2 } ] } } } }
+{ "transactionId": "t4", "timestamp": "1673384200000", "matchGameRoomStateChangedEvent": { "gameRoomInfo": { "gameRoomConfig": { "eventId": "QuickDraft_ONE_20230110", "matchId": "m3" }, "stateType": "MatchGameRoomStateType_Playing" } } }
+`
+
+func TestFindMatches(t *testing.T) {
+	matches, err := FindMatches(strings.NewReader(legacyMatchLog))
+	if err != nil {
+		t.Fatalf("FindMatches failed: %v", err)
+	}
+	want := []MatchRecord{{
+		MatchID:      "m1",
+		EventName:    "Traditional_Ladder",
+		Format:       "Constructed",
+		BestOf:       3,
+		Opponent:     "Opponent#12345",
+		OpponentRank: "Gold 2",
+		Games:        []GameResult{{Won: false, OnPlay: false, PlayKnown: true}, {Won: true, OnPlay: true, PlayKnown: true}, {Won: true, OnPlay: true, PlayKnown: true}},
+		Won:          true,
+		Start:        time.Date(2020, 1, 10, 20, 40, 5, 0, time.Local),
+		End:          time.Date(2020, 1, 10, 21, 10, 5, 0, time.Local),
+		Deck:         map[uint64]uint32{68000: 2, 68001: 1},
+		DeckName:     "Mono Green",
+	}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("wrong legacy matches.\nwant %+v\ngot  %+v", want, matches)
+	}
+	if d := matches[0].Duration(); d != 30*time.Minute {
+		t.Errorf("wrong duration: want 30m, got %v", d)
+	}
+	if onPlay, ok := matches[0].OnPlay(); onPlay || !ok {
+		t.Errorf("wrong OnPlay. want false, true, got %v, %v", onPlay, ok)
+	}
+
+	// The last match is not finished, and it is not returned.
+	l, err := ParseLog(strings.NewReader(modernMatchLog))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	want = []MatchRecord{{
+		MatchID:   "m2",
+		EventName: "QuickDraft_ONE_20230110",
+		Format:    "Limited",
+		BestOf:    1,
+		Opponent:  "Opponent",
+		Games:     []GameResult{{Won: false, OnPlay: true, PlayKnown: true}},
+		Won:       false,
+		Start:     time.Date(2023, 1, 10, 20, 40, 0, 0, time.Local),
+		End:       time.Date(2023, 1, 10, 20, 55, 1, 0, time.Local),
+		Deck:      map[uint64]uint32{68005: 1},
+	}}
+	if !reflect.DeepEqual(l.Matches, want) {
+		t.Errorf("wrong modern matches.\nwant %+v\ngot  %+v", want, l.Matches)
+	}
+	if len(l.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", l.Diagnostics)
+	}
+}
+
+func TestFindMatchesEmpty(t *testing.T) {
+	matches, err := FindMatches(strings.NewReader(legacyDecksLog))
+	if err != nil {
+		t.Fatalf("FindMatches failed: %v", err)
+	}
+	if matches == nil || len(matches) != 0 {
+		t.Errorf("want no matches, got %+v", matches)
+	}
+}
+
+// drawnMatchLog is a match where the player's deck is updated while it is played. The first game ends
+// in a draw, and the first turn of the second game is not in the logs.
+const drawnMatchLog = `[UnityCrossThreadLogger]<== Deck.GetDeckListsV3(10) {"id": 10, "payload": [{"id": "d1", "name": "Mono Green", "format": "Standard", "mainDeck": [68000, 2]}]}
+{ "transactionId": "t1", "matchGameRoomStateChangedEvent": { "gameRoomInfo": { "gameRoomConfig": { "eventId": "Play", "matchId": "m4", "reservedPlayers": [ { "playerName": "Player", "systemSeatId": 1, "teamId": 1 }, { "playerName": "Opponent", "systemSeatId": 2, "teamId": 2 } ] }, "stateType": "MatchGameRoomStateType_Playing" } } }
+{ "transactionId": "t2", "greToClientEvent": { "greToClientMessages": [ { "type": "GREMessageType_ConnectResp", "systemSeatIds": [ 1 ], "connectResp": { "deckMessage": { "deckCards": [ 68000, 68000 ] } } }, { "type": "GREMessageType_GameStateMessage", "systemSeatIds": [ 1 ], "gameStateMessage": { "gameInfo": { "gameNumber": 1, "matchWinCondition": "MatchWinCondition_Best2of3" }, "turnInfo": { "turnNumber": 1, "activePlayer": 1 } } } ] } }
+[UnityCrossThreadLogger]<== Deck.UpdateDeckV3(11) {"id": 11, "payload": {"id": "d1", "name": "Mono Green", "format": "Standard", "mainDeck": [68000, 3]}}
+{ "transactionId": "t3", "greToClientEvent": { "greToClientMessages": [ { "type": "GREMessageType_GameStateMessage", "systemSeatIds": [ 1 ], "gameStateMessage": { "gameInfo": { "gameNumber": 2 }, "turnInfo": { "turnNumber": 5, "activePlayer": 2 } } } ] } }
+{ "transactionId": "t4", "matchGameRoomStateChangedEvent": { "gameRoomInfo": { "gameRoomConfig": { "eventId": "Play", "matchId": "m4" }, "stateType": "MatchGameRoomStateType_MatchCompleted", "finalMatchResult": { "matchId": "m4", "resultList": [ { "scope": "MatchScope_Game", "result": "ResultType_Draw" }, { "scope": "MatchScope_Game", "result": "ResultType_WinLoss", "winningTeamId": 1 }, { "scope": "MatchScope_Match", "result": "ResultType_Draw" } ] } } } }
+`
+
+func TestFindMatchesDraws(t *testing.T) {
+	matches, err := FindMatches(strings.NewReader(drawnMatchLog))
+	if err != nil {
+		t.Fatalf("FindMatches failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("wrong number of matches. want 1, got %+v", matches)
+	}
+	m := matches[0]
+	want := []GameResult{{Drawn: true, OnPlay: true, PlayKnown: true}, {Won: true}}
+	if !reflect.DeepEqual(m.Games, want) {
+		t.Errorf("wrong games.\nwant %+v\ngot  %+v", want, m.Games)
+	}
+	if m.Won || !m.Drawn {
+		t.Errorf("the match should be a draw: %+v", m)
+	}
+	// The deck was changed after the match started.
+	if m.DeckName != "Mono Green" {
+		t.Errorf("wrong deck name. want %q, got %q", "Mono Green", m.DeckName)
+	}
+}
+
+func TestFindMatchesDiagnostics(t *testing.T) {
+	logs := drawnMatchLog + `{ "transactionId": "t5", "matchGameRoomStateChangedEvent": { "gameRoomInfo": "m5" } }
+[UnityCrossThreadLogger]<== Deck.UpdateDeckV3(12) {"id": 12, "payload": {"id": "d2", "name": "No Cards"}}
+[UnityCrossThreadLogger]<== Inventory.Updated {"id": 13, "payload": {"updates": []}}
+`
+	matches, diags, err := FindMatchesWithOptions(strings.NewReader(logs), ParseOptions{})
+	if err != nil {
+		t.Fatalf("FindMatchesWithOptions failed: %v", err)
+	}
+	// The booster message is not about matches or decks.
+	if len(matches) != 1 || len(diags) != 2 || diags[0].Type != MatchStateEvent || diags[1].Type != DeckUpdateEvent {
+		t.Errorf("wrong FindMatchesWithOptions result: %+v, %v", matches, diags)
+	}
+	if _, _, err := FindMatchesWithOptions(strings.NewReader(logs), ParseOptions{Strict: true}); err == nil {
+		t.Errorf("strict FindMatchesWithOptions should fail")
+	}
+}
@@ -0,0 +1,665 @@
+package collectionfinder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType is the kind of message found in the logs.
+type EventType int
+
+// The messages recognized by the Parser.
+const (
+	// CollectionEvent has the cards in the collection of the player.
+	CollectionEvent EventType = iota + 1
+	// InventoryEvent has the wildcards and currencies of the player.
+	InventoryEvent
+	// InventoryUpdateEvent is a change in the inventory, like opening a booster.
+	InventoryUpdateEvent
+	// DeckListsEvent has all the decks saved by the player.
+	DeckListsEvent
+	// DeckUpdateEvent has a deck created or updated by the player.
+	DeckUpdateEvent
+	// MatchEvent is sent when a match is created, in old versions of the game.
+	MatchEvent
+	// MatchStateEvent is sent when a match starts or ends.
+	MatchStateEvent
+	// GameMessagesEvent has the messages sent by the game server to the client during a match.
+	GameMessagesEvent
+	// DraftPickEvent is a card picked in a draft.
+	DraftPickEvent
+	// DiagnosticEvent is a message that was skipped because it couldn't be decoded.
+	DiagnosticEvent
+)
+
+var eventTypeNames = map[EventType]string{
+	CollectionEvent:      "Collection",
+	InventoryEvent:       "Inventory",
+	InventoryUpdateEvent: "InventoryUpdate",
+	DeckListsEvent:       "DeckLists",
+	DeckUpdateEvent:      "DeckUpdate",
+	MatchEvent:           "Match",
+	MatchStateEvent:      "MatchState",
+	GameMessagesEvent:    "GameMessages",
+	DraftPickEvent:       "DraftPick",
+	DiagnosticEvent:      "Diagnostic",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event is a message found in the logs.
+type Event struct {
+	Type EventType
+	// Method is the name of the message in the logs, e.g. "PlayerInventory.GetPlayerCardsV3".
+	Method string
+	// ID is the ID of the request, it is the same for a request and its response. It might be empty.
+	ID string
+	// Line is the line number where the message starts, starting at 1.
+	Line int
+	// Offset is the offset in bytes of the start of the line where the message starts.
+	Offset int64
+	// Time is the time of the latest timestamp in the logs before the message. It is zero if there
+	// wasn't any.
+	Time time.Time
+	// Payload is the JSON content of the message, without the wrapper of the old versions of the
+	// game ({"id": ..., "payload": ...}).
+	Payload json.RawMessage
+	// Request is the payload of the request, for the responses whose request is in the logs.
+	Request json.RawMessage
+
+	// Collection are the number of copies of each card by grpid, for CollectionEvent.
+	Collection map[uint64]uint32
+	// Inventory is set for InventoryEvent.
+	Inventory *PlayerInventory
+	// InventoryUpdate is set for InventoryUpdateEvent.
+	InventoryUpdate *InventoryUpdate
+	// Decks are set for DeckListsEvent and DeckUpdateEvent. In Player.log, the decks are in the
+	// InventoryEvent of StartHook.
+	Decks []Deck
+	// DraftPick is set for DraftPickEvent.
+	DraftPick *DraftPick
+
+	// match is the decoded message for MatchEvent, MatchStateEvent and GameMessagesEvent.
+	match *matchMsg
+	// Diagnostic is set for DiagnosticEvent.
+	Diagnostic *Diagnostic
+}
+
+// Diagnostic describes a message in the logs that was skipped because it couldn't be decoded, usually
+// because an update of the game changed its format.
+type Diagnostic struct {
+	// Line is the line number where the message starts.
+	Line int
+	// Method is the name of the message in the logs, and Type the type of event it would have been.
+	Method string
+	Type   EventType
+	// Reason is why the message was skipped.
+	Reason string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: %s message (%s): %s", d.Line, d.Type, d.Method, d.Reason)
+}
+
+const unityLoggerPrefix = "[UnityCrossThreadLogger]"
+
+// logMethods are the messages recognized by the Parser. Responses are written in the logs after
+// "<== " (or "Incoming " in old versions of the game), and requests after "==> ".
+//
+// Old versions of the game wrote output_log.txt, with the JSON in the same line as the method:
+//
+//	[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(12) {"id": 12, "payload": {...}}
+//
+// Current versions write Player.log, where the endpoints have different names, the ID of the request
+// is a GUID, and the JSON of the responses is in the next line:
+//
+//	[UnityCrossThreadLogger]==> StartHook {"id":"5c9b...","request":"{...}"}
+//	<== StartHook(5c9b...)
+//	{"InventoryInfo":{...},...}
+var logMethods = []struct {
+	name    string
+	request bool
+	typ     EventType
+}{
+	{"PlayerInventory.GetPlayerCardsV3", false, CollectionEvent},
+	{"PlayerInventory.GetPlayerInventory", false, InventoryEvent},
+	{"StartHook", false, InventoryEvent},
+	{"Inventory.Updated", false, InventoryUpdateEvent},
+	{"Deck.GetDeckListsV3", false, DeckListsEvent},
+	{"Deck.CreateDeckV3", false, DeckUpdateEvent},
+	{"Deck.UpdateDeckV3", false, DeckUpdateEvent},
+	{"DeckUpsertDeckV2", true, DeckUpdateEvent},
+	{"Event.MatchCreated", false, MatchEvent},
+	{"MatchGameRoomStateChangedEvent", false, MatchStateEvent},
+	{"GreToClientEvent", false, GameMessagesEvent},
+	{"Draft.MakePick", true, DraftPickEvent},
+	{"BotDraft_DraftPick", true, DraftPickEvent},
+	{"EventPlayerDraftMakePick", true, DraftPickEvent},
+}
+
+// lookupMethod returns the type of the events for method. paired is set for the requests of the
+// methods with recognized responses.
+func lookupMethod(method string, request bool) (typ EventType, paired bool) {
+	for _, m := range logMethods {
+		if m.name != method {
+			continue
+		}
+		if m.request == request {
+			typ = m.typ
+		} else if request {
+			paired = true
+		}
+	}
+	return typ, paired
+}
+
+// maxPendingRequests is the number of requests kept by the Parser while waiting for their responses.
+const maxPendingRequests = 256
+
+// logTimeLayouts are the formats of the timestamps in the logs, which depend on the locale of the
+// computer.
+var logTimeLayouts = []string{
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 3:04:05 PM",
+	"02.01.2006 15:04:05",
+}
+
+// parseLogTime parses the timestamp at the start of s, if there is one. The timestamps have no time
+// zone, the game writes them in the local time of the player.
+func parseLogTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range logTimeLayouts {
+		// The timestamp might be followed by more text (e.g. ": Match to ..."). The layouts have
+		// the same number of fields as the timestamps they match.
+		n := strings.Count(layout, " ") + 1
+		fields := strings.SplitN(s, " ", n+1)
+		if len(fields) < n {
+			continue
+		}
+		ts := strings.TrimRight(strings.Join(fields[:n], " "), ":")
+		if t, err := time.ParseInLocation(layout, ts, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseHeader returns the method, direction and request ID of a message header line, like
+// "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(12) {...". The ID is empty if it is not
+// in the header. In Player.log, the responses don't have the [UnityCrossThreadLogger] prefix.
+//
+// The messages of the match server have a different header:
+// "[UnityCrossThreadLogger]1/10/2023 8:40:00 PM: Match to 4C1A...: GreToClientEvent".
+func parseHeader(line string) (method string, request bool, id string, ok bool) {
+	rest := strings.TrimPrefix(line, unityLoggerPrefix)
+	if len(rest) == len(line) && !strings.HasPrefix(line, "<== ") {
+		return "", false, "", false
+	}
+	if i := strings.IndexAny(rest, "{["); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, ": Match to "); i >= 0 {
+		rest = rest[i+len(": Match to "):]
+		if j := strings.Index(rest, ": "); j >= 0 {
+			if fields := strings.Fields(rest[j+2:]); len(fields) > 0 {
+				return fields[0], false, "", true
+			}
+		}
+		return "", false, "", false
+	}
+	for _, marker := range []string{"<== ", "==> ", "Incoming "} {
+		i := strings.Index(rest, marker)
+		if i < 0 {
+			continue
+		}
+		method = strings.TrimSpace(rest[i+len(marker):])
+		if j := strings.IndexAny(method, " ("); j >= 0 {
+			if k := strings.Index(method, ")"); method[j] == '(' && k > j {
+				id = method[j+1 : k]
+			}
+			method = method[:j]
+		}
+		return method, marker == "==> ", id, true
+	}
+	return "", false, "", false
+}
+
+// bareMessages are the keys of the messages of the match server, by method.
+var bareMessages = map[string]string{
+	"MatchGameRoomStateChangedEvent": `"matchGameRoomStateChangedEvent"`,
+	"GreToClientEvent":               `"greToClientEvent"`,
+}
+
+// bareMessageMethod returns the method of a message of the match server that is written in the logs
+// without a header, or an empty string if line is not the start of one.
+func bareMessageMethod(line string) string {
+	if !strings.HasPrefix(line, "{") {
+		return ""
+	}
+	// The key is near the start of the message, after the transaction ID and timestamp.
+	if len(line) > 256 {
+		line = line[:256]
+	}
+	for method, key := range bareMessages {
+		if strings.Contains(line, key) {
+			return method
+		}
+	}
+	return ""
+}
+
+// arenaMessage is the wrapper around the JSON messages in the logs.
+type arenaMessage struct {
+	ID      json.RawMessage `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+	Params  json.RawMessage `json:"params"`
+	// Request is the payload of the requests in Player.log, as a JSON string.
+	Request json.RawMessage `json:"request"`
+}
+
+// unwrap returns the payload and the ID of the message.
+func (msg *arenaMessage) unwrap(raw json.RawMessage) (json.RawMessage, string) {
+	var id string
+	if len(msg.ID) > 0 && json.Unmarshal(msg.ID, &id) != nil {
+		// Old versions of the game use numbers as IDs.
+		id = string(msg.ID)
+	}
+	switch {
+	case len(msg.Payload) > 0:
+		return msg.Payload, id
+	case len(msg.Params) > 0:
+		return msg.Params, id
+	case len(msg.Request) > 0:
+		var s string
+		if json.Unmarshal(msg.Request, &s) == nil && json.Valid([]byte(s)) {
+			return json.RawMessage(s), id
+		}
+		return msg.Request, id
+	}
+	return raw, ""
+}
+
+// jsonScanner finds the end of a JSON value that spans several lines, without decoding it.
+type jsonScanner struct {
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// scan reads s, and returns the length of the prefix of s that completes the value, or -1 if the
+// value continues after s.
+func (js *jsonScanner) scan(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case js.escaped:
+			js.escaped = false
+		case js.inString && c == '\\':
+			js.escaped = true
+		case c == '"':
+			js.inString = !js.inString
+		case js.inString:
+		case c == '{' || c == '[':
+			js.depth++
+		case c == '}' || c == ']':
+			js.depth--
+			if js.depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// Parser reads the MTG Arena logs in a single pass, returning the recognized messages as events.
+type Parser struct {
+	// Strict makes Next fail when a message can't be decoded. Otherwise, Next returns a
+	// DiagnosticEvent for the message and continues with the next one.
+	Strict bool
+
+	reader *bufio.Reader
+	// line and offset are the number and offset of the next line.
+	line   int
+	offset int64
+	time   time.Time
+	// pending is a line that was read ahead, and has to be returned by the next readLine.
+	pending *logLine
+	// requests are the requests waiting for their responses, by ID.
+	requests map[string]pendingRequest
+}
+
+type pendingRequest struct {
+	method  string
+	payload json.RawMessage
+}
+
+type logLine struct {
+	text   string
+	num    int
+	offset int64
+}
+
+// NewParser returns a Parser that reads the logs from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{reader: bufio.NewReader(r), line: 1, requests: make(map[string]pendingRequest)}
+}
+
+// readLine returns the next line of the logs, without the line terminator.
+func (p *Parser) readLine() (logLine, error) {
+	if p.pending != nil {
+		l := *p.pending
+		p.pending = nil
+		return l, nil
+	}
+	text, err := p.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || text == "") {
+		if err != io.EOF {
+			err = fmt.Errorf("failed to read line %v", err)
+		}
+		return logLine{}, err
+	}
+	l := logLine{text: strings.TrimRight(text, "\r\n"), num: p.line, offset: p.offset}
+	p.line++
+	p.offset += int64(len(text))
+	return l, nil
+}
+
+func (p *Parser) unreadLine(l logLine) {
+	p.pending = &l
+}
+
+// Next returns the next recognized message in the logs. It returns io.EOF at the end of the logs.
+func (p *Parser) Next() (Event, error) {
+	for {
+		l, err := p.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		if strings.HasPrefix(l.text, unityLoggerPrefix) {
+			if t, ok := parseLogTime(l.text[len(unityLoggerPrefix):]); ok {
+				p.time = t
+			}
+		}
+		method, request, id, ok := parseHeader(l.text)
+		if !ok {
+			// Some versions of the game write the messages of the match server without a header.
+			if method = bareMessageMethod(l.text); method == "" {
+				continue
+			}
+		}
+		typ, paired := lookupMethod(method, request)
+		if typ == 0 && !paired {
+			continue
+		}
+
+		ev := Event{Type: typ, Method: method, ID: id, Line: l.num, Offset: l.offset, Time: p.time}
+		payload, msgID, err := p.readJSON(strings.TrimPrefix(l.text, unityLoggerPrefix))
+		if me, ok := err.(*brokenMessageError); ok {
+			if typ == 0 {
+				continue
+			}
+			return p.diagnose(ev, me.err)
+		}
+		if err != nil {
+			return Event{}, err
+		}
+		if payload == nil {
+			continue
+		}
+		if ev.ID == "" {
+			ev.ID = msgID
+		}
+		ev.Payload = payload
+		if paired && ev.ID != "" {
+			if len(p.requests) >= maxPendingRequests {
+				// The responses of these requests are not in the logs.
+				p.requests = make(map[string]pendingRequest)
+			}
+			p.requests[ev.ID] = pendingRequest{method, payload}
+		}
+		if typ == 0 {
+			continue
+		}
+		if req, ok := p.requests[ev.ID]; ok && !request && req.method == method {
+			ev.Request = req.payload
+			delete(p.requests, ev.ID)
+		}
+		if err := decodeEvent(&ev); err != nil {
+			return p.diagnose(ev, err)
+		}
+		return ev, nil
+	}
+}
+
+// diagnose returns a DiagnosticEvent for ev, which couldn't be decoded because of err. In strict mode,
+// it returns an error instead.
+func (p *Parser) diagnose(ev Event, err error) (Event, error) {
+	d := &Diagnostic{Line: ev.Line, Method: ev.Method, Type: ev.Type, Reason: err.Error()}
+	if p.Strict {
+		return Event{}, fmt.Errorf("failed to decode arena message at %v", d)
+	}
+	return Event{Type: DiagnosticEvent, Method: ev.Method, ID: ev.ID, Line: ev.Line, Offset: ev.Offset, Time: ev.Time, Payload: ev.Payload, Diagnostic: d}, nil
+}
+
+// brokenMessageError is returned by readJSON when the message is not valid JSON. The message might be
+// truncated because the game crashed, or mixed up with lines written by other threads.
+type brokenMessageError struct {
+	err error
+}
+
+func (e *brokenMessageError) Error() string {
+	return e.err.Error()
+}
+
+// readJSON returns the payload of the JSON message that starts in header, or in the line after it,
+// and the ID in the message. It returns a nil payload if there isn't one.
+//
+// If the message ends before the JSON is complete, or it is not valid JSON, readJSON returns a
+// brokenMessageError. A message ends at the end of the logs, or at the header of another message, which
+// is left to be read by the next call to Next.
+func (p *Parser) readJSON(header string) (json.RawMessage, string, error) {
+	start := strings.IndexAny(header, "{[")
+	text := ""
+	if start >= 0 {
+		text = header[start:]
+	} else {
+		// The JSON might start in the next line.
+		l, err := p.readLine()
+		if err == io.EOF {
+			return nil, "", nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		trimmed := strings.TrimSpace(l.text)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			p.unreadLine(l)
+			return nil, "", nil
+		}
+		text = trimmed
+	}
+
+	var js jsonScanner
+	var b strings.Builder
+	for {
+		if end := js.scan(text); end >= 0 {
+			b.WriteString(text[:end])
+			break
+		}
+		b.WriteString(text)
+		b.WriteString("\n")
+		l, err := p.readLine()
+		if err == io.EOF {
+			return nil, "", &brokenMessageError{errors.New("message truncated at the end of the logs")}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if _, _, _, ok := parseHeader(l.text); ok || bareMessageMethod(l.text) != "" {
+			p.unreadLine(l)
+			return nil, "", &brokenMessageError{fmt.Errorf("message truncated by the message at line %d", l.num)}
+		}
+		text = l.text
+	}
+
+	raw := json.RawMessage(b.String())
+	var msg arenaMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		// Not all messages are objects, those don't have a wrapper.
+		if _, ok := err.(*json.UnmarshalTypeError); ok && json.Valid(raw) {
+			return raw, "", nil
+		}
+		return nil, "", &brokenMessageError{err}
+	}
+	payload, id := msg.unwrap(raw)
+	return payload, id, nil
+}
+
+// checkFields returns an error if the JSON object in payload doesn't have all the fields. It is used to
+// detect changes in the format of the messages, which would otherwise be decoded as zero values.
+func checkFields(payload json.RawMessage, fields ...string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if _, ok := obj[f]; !ok {
+			return fmt.Errorf("missing field %q", f)
+		}
+	}
+	return nil
+}
+
+// decodeEvent fills in the typed fields of ev from its payload. It returns an error if the payload
+// doesn't have the expected format.
+func decodeEvent(ev *Event) error {
+	switch ev.Type {
+	case CollectionEvent:
+		var playerCards cardListMsg
+		if err := json.Unmarshal(ev.Payload, &playerCards); err != nil {
+			return fmt.Errorf("failed to decode collection: %v", err)
+		}
+		ev.Collection = make(map[uint64]uint32)
+		for txtID, count := range playerCards {
+			id, err := strconv.Atoi(txtID)
+			if err != nil {
+				return fmt.Errorf("found non-numeric ID %q: %v", txtID, err)
+			}
+			ev.Collection[uint64(id)] = count
+		}
+	case InventoryEvent:
+		ev.Inventory = new(PlayerInventory)
+		if ev.Method == "StartHook" {
+			var start startHookMsg
+			if err := json.Unmarshal(ev.Payload, &start); err != nil {
+				return fmt.Errorf("failed to decode inventory: %v", err)
+			}
+			if start.InventoryInfo == nil {
+				return fmt.Errorf("failed to decode inventory: missing field %q", "InventoryInfo")
+			}
+			info := start.InventoryInfo
+			ev.Inventory.WcCommon = info.WildCardCommons
+			ev.Inventory.WcUncommon = info.WildCardUnCommons
+			ev.Inventory.WcRare = info.WildCardRares
+			ev.Inventory.WcMythic = info.WildCardMythics
+			decks, err := decodeDecks(ev)
+			if err != nil {
+				return fmt.Errorf("failed to decode decks: %v", err)
+			}
+			ev.Decks = decks
+			break
+		}
+		if err := checkFields(ev.Payload, "wcCommon", "wcUncommon", "wcRare", "wcMythic"); err != nil {
+			return fmt.Errorf("failed to decode inventory: %v", err)
+		}
+		if err := json.Unmarshal(ev.Payload, ev.Inventory); err != nil {
+			return fmt.Errorf("failed to decode inventory: %v", err)
+		}
+	case DeckListsEvent, DeckUpdateEvent:
+		decks, err := decodeDecks(ev)
+		if err != nil {
+			return fmt.Errorf("failed to decode decks: %v", err)
+		}
+		ev.Decks = decks
+	case DraftPickEvent:
+		pick, err := decodeDraftPick(ev)
+		if err != nil {
+			return fmt.Errorf("failed to decode draft pick: %v", err)
+		}
+		ev.DraftPick = pick
+	case MatchEvent, MatchStateEvent, GameMessagesEvent:
+		m, err := decodeMatchMessage(ev)
+		if err != nil {
+			return fmt.Errorf("failed to decode match message: %v", err)
+		}
+		ev.match = m
+	case InventoryUpdateEvent:
+		var update inventoryUpdateJSON
+		if err := checkFields(ev.Payload, "context"); err != nil {
+			return fmt.Errorf("failed to decode inventory update: %v", err)
+		}
+		if err := json.Unmarshal(ev.Payload, &update); err != nil {
+			return fmt.Errorf("failed to decode inventory update: %v", err)
+		}
+		ev.InventoryUpdate = &InventoryUpdate{Context: update.Context}
+		contents := &ev.InventoryUpdate.Contents
+		for _, u := range update.Updates {
+			contents.CommonWildcards += u.Delta.WcCommonDelta
+			contents.UncommonWildcards += u.Delta.WcUncommonDelta
+			contents.RareWildcards += u.Delta.WcRareDelta
+			contents.MythicWildcards += u.Delta.WcMythicDelta
+			for _, c := range u.AetherizedCards {
+				contents.CardIds = append(contents.CardIds, c.GrpID)
+			}
+		}
+	}
+	return nil
+}
+
+// Stream parses the logs in r in the background, and sends the events to the returned channel. When
+// the logs end, or parsing fails, or ctx is done, the events channel is closed, and the error (nil at
+// the end of the logs) is sent to the error channel.
+func Stream(ctx context.Context, r io.Reader) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(events)
+		p := NewParser(r)
+		for {
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
+			ev, err := p.Next()
+			if err == io.EOF {
+				errc <- nil
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return events, errc
+}
@@ -0,0 +1,365 @@
+package collectionfinder
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = `Initialize engine version: 2018.4.1f1
+[UnityCrossThreadLogger]1/13/2020 10:41:04 PM
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(12) {"id": 12, "payload": {"playerId": "ABC", "wcCommon": 10, "wcUncommon": 5, "wcRare": 2, "wcMythic": 1}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(13)
+{
+  "id": 13,
+  "payload": {
+    "68000": 4,
+    "68001": 2
+  }
+}
+Some other line
+[UnityCrossThreadLogger]1/13/2020 10:45:00 PM
+[UnityCrossThreadLogger]<== Inventory.Updated {"id": 14, "payload": {"context": "Booster.Open", "updates": [{"delta": {"wcRareDelta": 1}, "aetherizedCards": [{"grpId": 68002}, {"grpId": 68003}]}]}}
+[UnityCrossThreadLogger]<== Inventory.Updated {"id": 15, "payload": {"context": "PlayerReward.OnMatchCompletedDaily", "updates": []}}
+[UnityCrossThreadLogger]==> Draft.MakePick {"jsonrpc": "2.0", "method": "Draft.MakePick", "params": {"draftId": "D1", "cardId": "68004", "pickNumber": "3"}, "id": "16"}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(17) {"id": 17, "payload": {"68000": 4, "68002": 1, "68003": 1}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(18) {"id": 18, "payload": {"playerId": "ABC", "wcCommon": 10, "wcUncommon": 5, "wcRare": 3, "wcMythic": 1}}`
+
+func TestParser(t *testing.T) {
+	p := NewParser(strings.NewReader(testLog))
+	var got []Event
+	for {
+		ev, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, ev)
+	}
+
+	want := []struct {
+		typ  EventType
+		line int
+	}{
+		{InventoryEvent, 3},
+		{CollectionEvent, 4},
+		{InventoryUpdateEvent, 14},
+		{InventoryUpdateEvent, 15},
+		{DraftPickEvent, 16},
+		{CollectionEvent, 17},
+		{InventoryEvent, 18},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of events. want %d, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Line != w.line {
+			t.Errorf("event %d mismatch. want %v at line %d, got %v at line %d", i, w.typ, w.line, got[i].Type, got[i].Line)
+		}
+	}
+
+	if off := got[1].Offset; !strings.HasPrefix(testLog[off:], "[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(13)") {
+		t.Errorf("wrong offset for the collection: %d", off)
+	}
+	if want := time.Date(2020, 1, 13, 22, 41, 4, 0, time.Local); !got[1].Time.Equal(want) {
+		t.Errorf("wrong time for the collection. want %v, got %v", want, got[1].Time)
+	}
+	if want := time.Date(2020, 1, 13, 22, 45, 0, 0, time.Local); !got[2].Time.Equal(want) {
+		t.Errorf("wrong time for the booster. want %v, got %v", want, got[2].Time)
+	}
+	if want := map[uint64]uint32{68000: 4, 68001: 2}; !reflect.DeepEqual(got[1].Collection, want) {
+		t.Errorf("wrong collection. want %v, got %v", want, got[1].Collection)
+	}
+	if got[0].Inventory.WcRare != 2 || got[0].Inventory.PlayerID != "ABC" {
+		t.Errorf("wrong inventory: %+v", got[0].Inventory)
+	}
+	if want := (DraftPick{DraftID: "D1", CardIDs: []uint64{68004}, PickNumber: 3}); got[4].DraftPick == nil || !reflect.DeepEqual(*got[4].DraftPick, want) {
+		t.Errorf("wrong draft pick. want %+v, got %+v", want, got[4].DraftPick)
+	}
+	if got[4].Method != "Draft.MakePick" || !strings.Contains(string(got[4].Payload), `"pickNumber": "3"`) {
+		t.Errorf("wrong draft pick: %s %s", got[4].Method, got[4].Payload)
+	}
+}
+
+func TestFindFunctions(t *testing.T) {
+	collections, err := FindCollection(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("FindCollection failed: %v", err)
+	}
+	if len(collections) != 2 || collections[1][68002] != 1 {
+		t.Errorf("wrong collections: %v", collections)
+	}
+
+	inventories, err := FindInventory(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("FindInventory failed: %v", err)
+	}
+	if len(inventories) != 2 || inventories[1].WcRare != 3 {
+		t.Errorf("wrong inventories: %+v", inventories)
+	}
+
+	boosters, err := FindBoosters(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("FindBoosters failed: %v", err)
+	}
+	want := []BoosterContents{{RareWildcards: 1, CardIds: []uint64{68002, 68003}}}
+	if !reflect.DeepEqual(boosters, want) {
+		t.Errorf("wrong boosters. want %+v, got %+v", want, boosters)
+	}
+
+	l, err := ParseLog(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if !reflect.DeepEqual(l.Collections, collections) || !reflect.DeepEqual(l.Inventories, inventories) || !reflect.DeepEqual(l.Boosters, boosters) {
+		t.Errorf("ParseLog doesn't match the Find functions: %+v", l)
+	}
+}
+
+const brokenLog = `[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(1)
+{
+  "id": 1,
+  "payload": {
+    "68000": 4,
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(2) {"id": 2, "payload": {"68000": 2}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(3) {"id": 3, "payload": {
+[UnityCrossThreadLogger]Client.SceneChange {"fromSceneName": "Home"
+"68000": 3}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(4) {"id": 4, "payload": {]}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(5) {"id": 5, "payload": {"68000": 5}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(6) {"id": 6, "payload": {"68000":
+`
+
+func TestBrokenMessages(t *testing.T) {
+	l, err := ParseLog(strings.NewReader(brokenLog))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(l.Collections) != 2 || l.Collections[0][68000] != 2 || l.Collections[1][68000] != 5 {
+		t.Errorf("wrong collections: %v", l.Collections)
+	}
+	want := []struct {
+		line   int
+		reason string
+	}{
+		{1, "truncated by the message at line 6"},
+		{7, "truncated by the message at line 10"},
+		{10, "invalid character"},
+		{12, "truncated at the end of the logs"},
+	}
+	if len(l.Diagnostics) != len(want) {
+		t.Fatalf("wrong number of diagnostics. want %d, got %d: %v", len(want), len(l.Diagnostics), l.Diagnostics)
+	}
+	for i, w := range want {
+		if d := l.Diagnostics[i]; d.Line != w.line || d.Type != CollectionEvent || !strings.Contains(d.Reason, w.reason) {
+			t.Errorf("wrong diagnostic %d. want line %d, %q, got %v", i, w.line, w.reason, d)
+		}
+	}
+
+	if _, err := ParseLogWithOptions(strings.NewReader(brokenLog), ParseOptions{Strict: true}); err == nil {
+		t.Errorf("strict ParseLog should fail")
+	}
+}
+
+const badMessagesLog = `[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3 {"id": 1, "payload": {"abc": 1}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(2) {"id": 2, "payload": {"playerId": "ABC", "wildcards": {"rare": 2}}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerInventory(3) {"id": 3, "payload": {"playerId": "ABC", "wcCommon": "10", "wcUncommon": 5, "wcRare": 2, "wcMythic": 1}}
+[UnityCrossThreadLogger]<== Inventory.Updated {"id": 4, "payload": {"updates": []}}
+[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3 {"id": 5, "payload": {"68000": 1}}
+`
+
+func TestDiagnostics(t *testing.T) {
+	l, err := ParseLog(strings.NewReader(badMessagesLog))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(l.Collections) != 1 || len(l.Inventories) != 0 || len(l.Boosters) != 0 {
+		t.Errorf("the bad messages should be skipped: %+v", l)
+	}
+	want := []struct {
+		line   int
+		typ    EventType
+		reason string
+	}{
+		{1, CollectionEvent, `non-numeric ID "abc"`},
+		{2, InventoryEvent, `missing field "wcCommon"`},
+		{3, InventoryEvent, "cannot unmarshal string"},
+		{4, InventoryUpdateEvent, `missing field "context"`},
+	}
+	if len(l.Diagnostics) != len(want) {
+		t.Fatalf("wrong number of diagnostics. want %d, got %d: %v", len(want), len(l.Diagnostics), l.Diagnostics)
+	}
+	for i, w := range want {
+		d := l.Diagnostics[i]
+		if d.Line != w.line || d.Type != w.typ || !strings.Contains(d.Reason, w.reason) {
+			t.Errorf("wrong diagnostic %d. want line %d, %v, %q, got %v", i, w.line, w.typ, w.reason, d)
+		}
+	}
+
+	if _, err := ParseLogWithOptions(strings.NewReader(badMessagesLog), ParseOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("strict ParseLog should fail at line 1, got %v", err)
+	}
+
+	collections, diags, err := FindCollectionWithOptions(strings.NewReader(badMessagesLog), ParseOptions{})
+	if err != nil || len(collections) != 1 || len(diags) != 1 || diags[0].Line != 1 {
+		t.Errorf("wrong FindCollectionWithOptions result: %v, %v, %v", collections, diags, err)
+	}
+	inventories, diags, err := FindInventoryWithOptions(strings.NewReader(badMessagesLog), ParseOptions{})
+	if err != nil || len(inventories) != 0 || len(diags) != 2 || diags[0].Line != 2 || diags[1].Line != 3 {
+		t.Errorf("wrong FindInventoryWithOptions result: %v, %v, %v", inventories, diags, err)
+	}
+	boosters, diags, err := FindBoostersWithOptions(strings.NewReader(badMessagesLog), ParseOptions{})
+	if err != nil || len(boosters) != 0 || len(diags) != 1 || diags[0].Line != 4 {
+		t.Errorf("wrong FindBoostersWithOptions result: %v, %v, %v", boosters, diags, err)
+	}
+	if _, _, err := FindBoostersWithOptions(strings.NewReader(badMessagesLog), ParseOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("strict FindBoostersWithOptions should fail at line 4, got %v", err)
+	}
+}
+
+func TestParseLogTimeLocal(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.FixedZone("UTC-3", -3*60*60)
+
+	got, ok := parseLogTime("1/10/2023 8:40:00 PM: Match to 4C1A: GreToClientEvent")
+	if !ok {
+		t.Fatalf("failed to parse the log time")
+	}
+	// The timestamps are in the local time of the player, not in UTC.
+	if want := time.Date(2023, 1, 10, 23, 40, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("wrong log time. want %v, got %v", want, got)
+	}
+}
+
+func TestStream(t *testing.T) {
+	events, errc := Stream(context.Background(), strings.NewReader(testLog))
+	n := 0
+	for range events {
+		n++
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if n != 7 {
+		t.Errorf("wrong number of events. want 7, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc = Stream(ctx, strings.NewReader(testLog))
+	<-events
+	cancel()
+	for range events {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("Stream should stop when the context is canceled, got %v", err)
+	}
+}
+
+const modernTestLog = `[UnityCrossThreadLogger]1/10/2023 8:21:06 PM
+[UnityCrossThreadLogger]==> StartHook {"id":"5c9b2a1e-1111","request":"{\"PlayerId\":\"ABC\",\"ClientVersion\":\"2023.1\"}"}
+[UnityCrossThreadLogger]==> Log.BI {"id":"7777","request":"{}"}
+[UnityCrossThreadLogger]1/10/2023 8:21:07 PM
+<== StartHook(5c9b2a1e-1111)
+{"InventoryInfo":{"SeqId":1,"Gems":1500,"Gold":2000,"WildCardCommons":30,"WildCardUnCommons":20,"WildCardRares":5,"WildCardMythics":2},"Decks":{}}
+[UnityCrossThreadLogger]1/10/2023 8:30:00 PM
+[UnityCrossThreadLogger]==> BotDraft_DraftPick {"id":"8888","request":"{\"EventName\":\"QuickDraft_ONE\",\"PickInfo\":{\"CardIds\":[\"79412\"],\"PackNumber\":1,\"PickNumber\":3}}"}
+<== BotDraft_DraftPick(8888)
+{"CurrentModule":"BotDraft"}`
+
+func TestParserModernLog(t *testing.T) {
+	p := NewParser(strings.NewReader(modernTestLog))
+	var got []Event
+	for {
+		ev, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 2 {
+		t.Fatalf("wrong number of events. want 2, got %d: %+v", len(got), got)
+	}
+
+	start := got[0]
+	if start.Type != InventoryEvent || start.Method != "StartHook" || start.ID != "5c9b2a1e-1111" || start.Line != 5 {
+		t.Errorf("wrong StartHook event: %+v", start)
+	}
+	if want := (PlayerInventory{WcCommon: 30, WcUncommon: 20, WcRare: 5, WcMythic: 2}); *start.Inventory != want {
+		t.Errorf("wrong inventory. want %+v, got %+v", want, *start.Inventory)
+	}
+	if want := time.Date(2023, 1, 10, 20, 21, 7, 0, time.Local); !start.Time.Equal(want) {
+		t.Errorf("wrong time. want %v, got %v", want, start.Time)
+	}
+	if want := `{"PlayerId":"ABC","ClientVersion":"2023.1"}`; string(start.Request) != want {
+		t.Errorf("wrong request. want %s, got %s", want, start.Request)
+	}
+
+	pick := got[1]
+	if pick.Type != DraftPickEvent || pick.ID != "8888" || !strings.Contains(string(pick.Payload), `"PickNumber":3`) {
+		t.Errorf("wrong draft pick: %+v", pick)
+	}
+	if want := (DraftPick{DraftID: "QuickDraft_ONE", CardIDs: []uint64{79412}, PackNumber: 1, PickNumber: 3}); pick.DraftPick == nil || !reflect.DeepEqual(*pick.DraftPick, want) {
+		t.Errorf("wrong bot draft pick. want %+v, got %+v", want, pick.DraftPick)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		line    string
+		method  string
+		request bool
+		id      string
+		ok      bool
+	}{
+		{"[UnityCrossThreadLogger]<== PlayerInventory.GetPlayerCardsV3(12) {", "PlayerInventory.GetPlayerCardsV3", false, "12", true},
+		{"[UnityCrossThreadLogger]<== Inventory.Updated {", "Inventory.Updated", false, "", true},
+		{"[UnityCrossThreadLogger]==> Draft.MakePick {", "Draft.MakePick", true, "", true},
+		{"[UnityCrossThreadLogger]4/10/2019 9:13:37 PM (-1) Incoming Event.MatchCreated {", "Event.MatchCreated", false, "", true},
+		{"<== StartHook(5c9b2a1e-1111)", "StartHook", false, "5c9b2a1e-1111", true},
+		{"[UnityCrossThreadLogger]1/10/2023 8:21:06 PM", "", false, "", false},
+		{"Some other line <== Foo", "", false, "", false},
+	}
+	for _, test := range tests {
+		method, request, id, ok := parseHeader(test.line)
+		if method != test.method || request != test.request || id != test.id || ok != test.ok {
+			t.Errorf("parseHeader(%q) = %q, %v, %q, %v. want %q, %v, %q, %v", test.line, method, request, id, ok, test.method, test.request, test.id, test.ok)
+		}
+	}
+}
+
+func TestDraftPicks(t *testing.T) {
+	logs := `[UnityCrossThreadLogger]==> EventPlayerDraftMakePick {"id":"1","request":"{\"DraftId\":\"D2\",\"GrpIds\":[79412,79413],\"Pack\":2,\"Pick\":5}"}
+[UnityCrossThreadLogger]==> BotDraft_DraftPick {"id":"2","request":"{\"EventName\":\"QuickDraft_DMU\",\"PickInfo\":{\"EventName\":\"QuickDraft_DMU\",\"CardId\":\"80001\",\"PackNumber\":0,\"PickNumber\":1}}"}
+[UnityCrossThreadLogger]==> Draft.MakePick {"jsonrpc": "2.0", "method": "Draft.MakePick", "params": {"draftId": "D3", "cardId": "abc", "pickNumber": "1"}, "id": "3"}
+`
+	l, err := ParseLog(strings.NewReader(logs))
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(l.Diagnostics) != 1 || l.Diagnostics[0].Line != 3 || l.Diagnostics[0].Type != DraftPickEvent {
+		t.Errorf("the pick with a bad card ID should be skipped: %v", l.Diagnostics)
+	}
+
+	p := NewParser(strings.NewReader(logs))
+	want := []DraftPick{
+		{DraftID: "D2", CardIDs: []uint64{79412, 79413}, PackNumber: 2, PickNumber: 5},
+		{DraftID: "QuickDraft_DMU", CardIDs: []uint64{80001}, PackNumber: 0, PickNumber: 1},
+	}
+	for _, w := range want {
+		ev, err := p.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if ev.Type != DraftPickEvent || ev.DraftPick == nil || !reflect.DeepEqual(*ev.DraftPick, w) {
+			t.Errorf("wrong draft pick. want %+v, got %+v", w, ev.DraftPick)
+		}
+	}
+}
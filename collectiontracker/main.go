@@ -2,21 +2,81 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mvanotti/mtgassistant/carddb"
 	"github.com/mvanotti/mtgassistant/collectionfinder"
+	"github.com/mvanotti/mtgassistant/sets"
 )
 
 var (
-	mtgOutputLog = flag.String("log_file", `${USERPROFILE}\AppData\LocalLow\Wizards Of The Coast\MTGA\output_log.txt`, "Filepath of the MTG Arena Output Log, typically stored in an MTG folder inside C:\\Users")
+	mtgOutputLog = flag.String("log_file", collectionfinder.DefaultLogFile(), "Filepath of the MTG Arena log (Player.log, or output_log.txt in old versions), typically stored in an MTG folder inside C:\\Users")
 	mtgDataPath  = flag.String("mtg_data", `C:\Program Files (x86)\Wizards of the Coast\MTGA\MTGA_Data\Downloads\Data`, "Path to the Downloads\\Data folder inside the MTG Arena Install Directory")
-	mtgSet       = flag.String("set", "THB", "Expansion codename")
+	cacheDir     = flag.String("cache_dir", carddb.DefaultCacheDir(), "Directory for the card database cache. Empty disables the cache.")
+	mtgSet       = flag.String("set", "", "Expansion codename. Defaults to the newest Standard set.")
+	setsData     = flag.String("sets_data", "", "Path to a sets data file with release dates and formats. Uses the built-in data if empty.")
+	follow       = flag.Bool("follow", false, "Keep reading the log while the game is running, and print the missing cards every time the collection changes, and the boosters as they are opened.")
 )
 
+// newestStandardSet returns the code of the most recently released set in Standard.
+func newestStandardSet(catalog *sets.Catalog) (string, error) {
+	codes, ok := catalog.Format("Standard")
+	if !ok || len(codes) == 0 {
+		return "", errors.New("no Standard sets found")
+	}
+	newest, _ := catalog.Set(codes[0])
+	for _, code := range codes[1:] {
+		if s, _ := catalog.Set(code); s.Order > newest.Order {
+			newest = s
+		}
+	}
+	return newest.Code, nil
+}
+
+// missingCards returns how many rares and mythic rares of set are missing from collection (card counts
+// by card ID) to have four copies of each.
+func missingCards(db carddb.CardDB, collection map[uint64]uint32, set string) (missingRares uint32, missingMythics uint32) {
+	rares := uint32(0)
+	mythics := uint32(0)
+	for _, card := range db.CardsInSet(set) {
+		if !card.IsPrimaryFace() {
+			continue
+		}
+		if card.Rarity == carddb.MythicRarity {
+			mythics++
+		}
+		if card.Rarity == carddb.RareRarity {
+			rares++
+		}
+	}
+
+	missingRares = rares * 4
+	missingMythics = mythics * 4
+
+	for id, count := range carddb.CollapseFaces(db, collection) {
+		card := db.GetCardByID(id)
+		if card == nil || !strings.EqualFold(card.Set, set) {
+			continue
+		}
+		if count > 4 {
+			count = 4
+		}
+		if card.Rarity == carddb.MythicRarity {
+			missingMythics -= count
+		} else if card.Rarity == carddb.RareRarity {
+			missingRares -= count
+		}
+	}
+	return missingRares, missingMythics
+}
+
 func main() {
 	flag.Parse()
 	log.Println("Parsing MTGA Log...")
@@ -25,57 +85,100 @@ func main() {
 		log.Fatalf("failed to open log file: %v", err)
 	}
 	defer f.Close()
-	cardLists, err := collectionfinder.FindCollection(f)
+	cardLists, diags, err := collectionfinder.FindCollectionWithOptions(f, collectionfinder.ParseOptions{})
 	if err != nil {
 		log.Fatalf("failed to parse mtga logs: %v", err)
 	}
+	if len(diags) > 0 {
+		log.Printf("Skipped %d messages that couldn't be decoded", len(diags))
+	}
 	if len(cardLists) < 1 {
 		log.Fatal("no decks found in the mtg logs. make sure to enable logs in the Arena app.")
 	}
 	cardList := cardLists[len(cardLists)-1]
+	logSize, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		log.Fatalf("failed to get log size: %v", err)
+	}
 
 	log.Println("Parsing MTG Data Files...")
-	db, err := carddb.CreateLibrary(*mtgDataPath)
+	db, err := carddb.CreateCachedLibrary(*mtgDataPath, *cacheDir, "en-US")
 	if err != nil {
 		log.Fatalf("createLibrary failed: %v", err)
 	}
 
-	predicate := func(c carddb.Card) bool {
-		if c.Set != *mtgSet {
-			return false
+	if *mtgSet == "" {
+		data, err := sets.LoadData(*setsData)
+		if err != nil {
+			log.Fatalf("failed to load sets data: %v", err)
 		}
-		if c.Rarity != carddb.MythicRarity && c.Rarity != carddb.RareRarity {
-			return false
+		*mtgSet, err = newestStandardSet(sets.Discover(db, data))
+		if err != nil {
+			log.Fatalf("failed to pick a set: %v", err)
 		}
-		return true
+		log.Printf("Using set %s", *mtgSet)
 	}
 
-	rares := uint32(0)
-	mythics := uint32(0)
-	for _, card := range db.Filter(predicate) {
-		if card.Rarity == carddb.MythicRarity {
-			mythics++
-		}
-		if card.Rarity == carddb.RareRarity {
-			rares++
-		}
-	}
+	missingRares, missingMythics := missingCards(db, cardList, *mtgSet)
+	fmt.Printf("Missing Rares: %d\n", missingRares)
+	fmt.Printf("Missing Mythics: %d\n", missingMythics)
 
-	missingRares := rares * 4
-	missingMythics := mythics * 4
+	if *follow {
+		followCollection(db, os.ExpandEnv(*mtgOutputLog), logSize, *mtgSet, missingRares, missingMythics)
+	}
+}
 
-	for id, count := range cardList {
+// printBooster prints the cards and wildcards in an opened booster.
+func printBooster(w io.Writer, db carddb.CardDB, when string, booster collectionfinder.BoosterContents) {
+	fmt.Fprintf(w, "[%s] Opened a booster:\n", when)
+	for _, id := range booster.CardIds {
 		card := db.GetCardByID(id)
-		if card.Set != *mtgSet {
+		if card == nil {
+			fmt.Fprintf(w, "1 Unknown card #%d\n", id)
 			continue
 		}
-		if card.Rarity == carddb.MythicRarity {
-			missingMythics -= count
-		} else if card.Rarity == carddb.RareRarity {
-			missingRares -= count
-		}
+		fmt.Fprintf(w, "1 %s (%s) %s\n", card.Name, card.Set, card.CollectorNumber)
 	}
+	fmt.Fprintf(w, "Common Wildcards: %d, Uncommon Wildcards: %d, Rare Wildcards: %d, Mythic Wildcards: %d\n",
+		booster.CommonWildcards, booster.UncommonWildcards, booster.RareWildcards, booster.MythicWildcards)
+}
 
-	fmt.Printf("Missing Rares: %d\n", missingRares)
-	fmt.Printf("Missing Mythics: %d\n", missingMythics)
+// followCollection follows the log file at path, and prints the missing cards of set whenever they
+// change, and the boosters that are opened. The first logSize bytes of the log were already read, so
+// their messages are skipped.
+func followCollection(db carddb.CardDB, path string, logSize int64, set string, missingRares uint32, missingMythics uint32) {
+	log.Println("Following MTGA Log...")
+	events, errc := collectionfinder.Follow(context.Background(), path)
+	live := false
+	lastOffset := int64(0)
+	for ev := range events {
+		// Follow starts from the beginning of the log. The offsets go back to 0 when the game
+		// restarts, and all the messages in the new log are new.
+		live = live || ev.Offset >= logSize || ev.Offset < lastOffset
+		lastOffset = ev.Offset
+		if live && ev.Type == collectionfinder.DiagnosticEvent {
+			log.Printf("Skipped a message that couldn't be decoded: %v", ev.Diagnostic)
+		}
+		if !live {
+			continue
+		}
+		when := ev.Time.Format("2006-01-02 15:04:05")
+		if ev.Time.IsZero() {
+			when = "-"
+		}
+		if ev.Type == collectionfinder.InventoryUpdateEvent && ev.InventoryUpdate.Context == "Booster.Open" {
+			printBooster(os.Stdout, db, when, ev.InventoryUpdate.Contents)
+			continue
+		}
+		if ev.Type != collectionfinder.CollectionEvent {
+			continue
+		}
+		rares, mythics := missingCards(db, ev.Collection, set)
+		if rares == missingRares && mythics == missingMythics {
+			continue
+		}
+		missingRares, missingMythics = rares, mythics
+		fmt.Printf("[%s] Missing Rares: %d, Missing Mythics: %d\n", when, missingRares, missingMythics)
+	}
+	log.Fatalf("failed to follow mtga logs: %v", <-errc)
 }
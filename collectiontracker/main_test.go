@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+	"github.com/mvanotti/mtgassistant/carddb/carddbtest"
+	"github.com/mvanotti/mtgassistant/collectionfinder"
+	"github.com/mvanotti/mtgassistant/sets"
+)
+
+func TestMissingCards(t *testing.T) {
+	b := carddbtest.New()
+	b.AddCard("Rare One").GrpID(1).Set("WOE", "1").Rarity(carddb.RareRarity)
+	b.AddCard("Rare Two").GrpID(2).Set("WOE", "2").Rarity(carddb.RareRarity)
+	b.AddCard("Mythic").GrpID(3).Set("WOE", "3").Rarity(carddb.MythicRarity)
+	b.AddCard("Common").GrpID(4).Set("WOE", "4").Rarity(carddb.CommonRarity)
+	front := b.AddCard("Front").GrpID(5).Set("WOE", "5").Rarity(carddb.RareRarity)
+	back := b.AddCard("Back").GrpID(6).Set("WOE", "5").Rarity(carddb.RareRarity)
+	front.Face(back, carddb.DFCFrontFace, carddb.DFCBackFace)
+	b.AddCard("Other Set Rare").GrpID(7).Set("TLA", "1").Rarity(carddb.RareRarity)
+	db, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build card database: %v", err)
+	}
+
+	// The back face counts as the front, and cards beyond the fourth copy don't count.
+	collection := map[uint64]uint32{1: 4, 2: 7, 3: 1, 4: 4, 6: 2, 7: 4}
+	rares, mythics := missingCards(db, collection, "woe")
+	if rares != 2 || mythics != 3 {
+		t.Errorf("missingCards mismatch. want 2 rares and 3 mythics, got %d and %d", rares, mythics)
+	}
+
+	newest, err := newestStandardSet(sets.Discover(db, sets.DefaultData()))
+	if err != nil {
+		t.Fatalf("newestStandardSet failed: %v", err)
+	}
+	if newest != "TLA" {
+		t.Errorf("newestStandardSet mismatch. want TLA, got %s", newest)
+	}
+}
+
+func TestPrintBooster(t *testing.T) {
+	b := carddbtest.New()
+	b.AddCard("Rare One").GrpID(1).Set("WOE", "1").Rarity(carddb.RareRarity)
+	db, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build card database: %v", err)
+	}
+
+	var out bytes.Buffer
+	printBooster(&out, db, "-", collectionfinder.BoosterContents{CardIds: []uint64{1, 99}, RareWildcards: 1})
+	want := "[-] Opened a booster:\n1 Rare One (WOE) 1\n1 Unknown card #99\n" +
+		"Common Wildcards: 0, Uncommon Wildcards: 0, Rare Wildcards: 1, Mythic Wildcards: 0\n"
+	if out.String() != want {
+		t.Errorf("wrong booster output.\nwant %q\ngot  %q", want, out.String())
+	}
+}
@@ -15,12 +15,14 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/atotto/clipboard"
 	"github.com/mvanotti/mtgassistant/carddb"
 	"github.com/mvanotti/mtgassistant/collectionfinder"
+	"github.com/mvanotti/mtgassistant/sets"
 )
 
 var basicLandNames = map[string]bool{
@@ -32,9 +34,10 @@ var basicLandNames = map[string]bool{
 }
 
 type deckHelper struct {
-	enabledExpansions map[string]bool   // expansions that are available.
-	db                carddb.CardDB     // database of all magic cards in the arena.
-	collection        map[uint64]uint32 // The user's card collection.
+	enabledExpansions map[string]bool         // expansions that are available.
+	db                carddb.CardDB           // database of all magic cards in the arena.
+	collection        map[uint64]uint32       // The user's card collection.
+	savedDecks        []collectionfinder.Deck // decks saved in the game.
 }
 
 var cardRarity = map[uint64]string{
@@ -47,11 +50,17 @@ var cardRarity = map[uint64]string{
 }
 
 var (
-	mtgOutputLog  = flag.String("log_file", `${USERPROFILE}\AppData\LocalLow\Wizards Of The Coast\MTGA\output_log.txt`, "Filepath of the MTG Arena Output Log, typically stored in an MTG folder inside C:\\Users")
+	mtgOutputLog  = flag.String("log_file", collectionfinder.DefaultLogFile(), "Filepath of the MTG Arena log (Player.log, or output_log.txt in old versions), typically stored in an MTG folder inside C:\\Users")
 	deckPath      = flag.String("deck", "", "Path to the file containing your mtga deck.")
 	mtgDataPath   = flag.String("mtg_data", `C:\Program Files (x86)\Wizards of the Coast\MTGA\MTGA_Data\Downloads\Data`, "Path to the Downloads\\Data folder inside the MTG Arena Install Directory")
-	enabledSets   = flag.String("sets", "STD", "Comma separated list of enabled sets. The string `STD` refers to all standard sets, and `ALL` to all sets (historic).")
+	cacheDir      = flag.String("cache_dir", carddb.DefaultCacheDir(), "Directory for the card database cache. Empty disables the cache.")
+	enabledSets   = flag.String("sets", "STD", "Comma separated list of enabled sets and formats (e.g. Historic, Explorer). The string `STD` refers to all standard sets, and `ALL` to all sets.")
+	setsData      = flag.String("sets_data", "", "Path to a sets data file with release dates and formats. Uses the built-in data if empty.")
 	fromClipboard = flag.Bool("clipboard", false, "If set to true, will read the deck from the clipboard instead of a file.")
+	language      = flag.String("lang", "en-US", "Language for the card names in the output (e.g. es-ES, pt-BR). Decks can be in any language.")
+	listTokens    = flag.Bool("tokens", false, "Also list the tokens that the cards in the deck create.")
+	allSaved      = flag.Bool("saved_decks", false, "Check all the decks saved in the game, read from the log, instead of a deck file.")
+	savedName     = flag.String("deck_name", "", "Check the deck saved in the game with this name, read from the log, instead of a deck file.")
 )
 
 type card struct {
@@ -61,12 +70,10 @@ type card struct {
 	cc    string
 }
 
-var ignoredLines = map[string]bool{
-	"Deck":      true,
-	"Sideboard": true,
-	"Commander": true,
-	"":          true,
-}
+// countRegexp matches the lines that start with a number of copies. The other lines are section
+// headers (Deck, Sideboard, Commander, Companion), which are translated in localized clients (e.g.
+// Mazo, Banquillo, Comandante, Compañero), and are ignored.
+var countRegexp *regexp.Regexp = regexp.MustCompile(`^[0-9]+\s`)
 
 var mtgaRegexp *regexp.Regexp = regexp.MustCompile(`^([1-9][0-9]*) (.*) \(([A-Z0-9]{3})\) ?(.*)?$`)
 
@@ -81,7 +88,7 @@ func parseDeck(r io.Reader) ([]card, error) {
 	lineNum := 0
 	for scanner.Scan() {
 		ln := strings.TrimSpace(scanner.Text())
-		if ignoredLines[ln] {
+		if !countRegexp.MatchString(ln) {
 			continue
 		}
 		ls := mtgaRegexp.FindStringSubmatch(ln)
@@ -114,21 +121,51 @@ func (helper deckHelper) isExpansionEnabled(set string) bool {
 	return helper.enabledExpansions[set]
 }
 
+// unknownCardError returns an error for a card name that is not in the database, suggesting similar names.
+func unknownCardError(db carddb.CardDB, name string) error {
+	suggestions := db.SuggestNames(name, 3)
+	if len(suggestions) == 0 {
+		return fmt.Errorf("Card %q not found", name)
+	}
+	for i, s := range suggestions {
+		suggestions[i] = strconv.Quote(s)
+	}
+	return fmt.Errorf("Card %q not found. Did you mean %s?", name, strings.Join(suggestions, " or "))
+}
+
 func (helper deckHelper) deckDistance(deck []card) (map[uint64]uint32, error) {
 	res := make(map[uint64]uint32)
 	for _, c := range deck {
 		candidates := make([]*carddb.Card, 0)
+		// The set and collector number identify the exact printing in the deck, if it exists.
+		printing := helper.db.GetCardBySetNumber(c.expn, c.cc)
 		cs := helper.db.GetCard(c.name)
+		if len(cs) == 0 && printing != nil {
+			cs = helper.db.GetCardsByTitleID(printing.TitleID)
+		}
+		if len(cs) == 0 {
+			return nil, unknownCardError(helper.db, c.name)
+		}
 		for _, card := range cs {
 			if !helper.isExpansionEnabled(card.Set) {
 				continue
 			}
+			if card == printing {
+				// Prefer crafting the printing that is in the deck.
+				candidates = append([]*carddb.Card{card}, candidates...)
+				continue
+			}
 			candidates = append(candidates, card)
 		}
 
 		if len(candidates) < 1 {
 			return nil, fmt.Errorf("Card %q not found in enabled sets", c.name)
 		}
+		if candidates[0].Rarity == carddb.BasicLandRarity {
+			// Basic lands are not part of the collection, and can't be crafted. They might be
+			// in a different language, so they are not filtered out by parseDeck.
+			continue
+		}
 
 		count := uint32(c.count)
 		for _, candidate := range candidates {
@@ -145,48 +182,129 @@ func (helper deckHelper) deckDistance(deck []card) (map[uint64]uint32, error) {
 	return res, nil
 }
 
-var allSets = []string{"SCG", "ALA", "VMA", "M19", "DIS", "THS", "ORI", "IKO", "ODY", "SOI", "EMN", "DAR", "PLS", "RAV", "ME2", "M11", "NPH", "ArenaSUP", "SHM", "ARB", "M15", "UND", "ROE", "CHK", "BFZ", "THB", "MIR", "DST", "ANA", "10E", "WWK", "M14", "RIX", "WTH", "M10", "ZEN", "AVR", "8ED", "LRW", "DDF", "SOM", "RTR", "DTK", "G18", "MRD", "5DN", "ISD", "M13", "RNA", "ONS", "JOU", "9ED", "ME4", "DKA", "GTC", "C13", "ELD", "MMQ", "MOR", "CMD", "MMA", "WAR", "M20", "INV", "SOK", "CONF", "AKH", "XLN", "GRN", "MH1"}
-var stdSets = []string{"THB", "ELD", "M20", "WAR", "GRN", "RNA"}
+// deckTokens returns the names of the tokens created by the cards in the deck, with the names of the
+// cards that create each of them. Cards that are not in the database are ignored.
+func (helper deckHelper) deckTokens(deck []card) map[string][]string {
+	res := make(map[string][]string)
+	seen := make(map[string]bool) // token and card names already in res.
+	for _, c := range deck {
+		dc := helper.db.GetCardBySetNumber(c.expn, c.cc)
+		if cs := helper.db.GetCard(c.name); dc == nil && len(cs) > 0 {
+			dc = cs[0]
+		}
+		if dc == nil {
+			continue
+		}
+		dc = dc.PrimaryFace()
+		for _, token := range dc.AllTokens() {
+			if key := token.Name + "\x00" + dc.Name; !seen[key] {
+				seen[key] = true
+				res[token.Name] = append(res[token.Name], dc.Name)
+			}
+		}
+	}
+	return res
+}
+
+// findSavedDecks returns the saved deck with the given name, or all the saved decks if name is empty.
+func (helper deckHelper) findSavedDecks(name string) ([]collectionfinder.Deck, error) {
+	if name == "" {
+		return helper.savedDecks, nil
+	}
+	var names []string
+	for _, d := range helper.savedDecks {
+		if strings.EqualFold(d.Name, name) {
+			return []collectionfinder.Deck{d}, nil
+		}
+		names = append(names, strconv.Quote(d.Name))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Deck %q not found. There are no saved decks in the log", name)
+	}
+	return nil, fmt.Errorf("Deck %q not found. The saved decks are %s", name, strings.Join(names, ", "))
+}
+
+// savedDeckCards returns the cards in a saved deck, in all its zones, like parseDeck.
+func (helper deckHelper) savedDeckCards(d collectionfinder.Deck) ([]card, error) {
+	counts := make(map[uint64]uint32)
+	for _, zone := range []map[uint64]uint32{d.MainDeck, d.Sideboard, d.Commander} {
+		for id, count := range zone {
+			counts[id] += count
+		}
+	}
+	for id, count := range d.Companion {
+		// The companion is usually in the sideboard too.
+		if counts[id] < count {
+			counts[id] = count
+		}
+	}
+	ids := make([]uint64, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var cls []card
+	for _, id := range ids {
+		c := helper.db.GetCardByID(id)
+		if c == nil {
+			return nil, fmt.Errorf("card id %d in deck %q not found", id, d.Name)
+		}
+		c = c.PrimaryFace()
+		cls = append(cls, card{count: int(counts[id]), name: c.Name, expn: c.Set, cc: c.CollectorNumber})
+	}
+	return cls, nil
+}
 
-func parseExpansions(enabledSets string) (map[string]bool, error) {
+// parseExpansions returns the set codes enabled by enabledSets, a comma separated list of set codes and
+// format names. STD is an alias for the Standard format, and ALL enables every set.
+func parseExpansions(catalog *sets.Catalog, enabledSets string) (map[string]bool, error) {
 	var enabledExpansions = make(map[string]bool)
-	for _, set := range allSets {
-		enabledExpansions[set] = false
+	for _, set := range catalog.Sets() {
+		enabledExpansions[set.Code] = false
 	}
 
-	sets := strings.Split(enabledSets, ",")
-	for _, set := range sets {
-		if set == "ALL" {
-			for _, set := range allSets {
-				enabledExpansions[set] = true
+	for _, name := range strings.Split(enabledSets, ",") {
+		name = strings.TrimSpace(name)
+		if name == "ALL" {
+			for _, set := range catalog.Sets() {
+				enabledExpansions[set.Code] = true
 			}
 			break
 		}
-		if set == "STD" {
-			for _, set := range stdSets {
-				enabledExpansions[set] = true
+		if name == "STD" {
+			name = "Standard"
+		}
+		if codes, ok := catalog.Format(name); ok {
+			for _, code := range codes {
+				enabledExpansions[code] = true
 			}
 			continue
 		}
-		if _, ok := enabledExpansions[set]; !ok {
-			return nil, fmt.Errorf("invalid set: %v", set)
+		set, ok := catalog.Set(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid set: %v", name)
 		}
-		enabledExpansions[set] = true
+		enabledExpansions[set.Code] = true
 	}
 	return enabledExpansions, nil
 }
 
-func newDeckHelper(mtgOutputLogPath string, mtgDataPath string, enabledSets string) (*deckHelper, error) {
+func newDeckHelper(mtgOutputLogPath string, mtgDataPath string, cacheDir string, setsDataPath string, enabledSets string, lang string) (*deckHelper, error) {
 	log.Println("Parsing MTGA Log...")
 	f, err := os.Open(os.ExpandEnv(mtgOutputLogPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file: %v", err)
 	}
 	defer f.Close()
-	cardLists, err := collectionfinder.FindCollection(f)
+	mtgaLog, err := collectionfinder.ParseLog(f)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse mtga logs: %v", err)
 	}
+	if len(mtgaLog.Diagnostics) > 0 {
+		log.Printf("Skipped %d messages that couldn't be decoded", len(mtgaLog.Diagnostics))
+	}
+	cardLists := mtgaLog.Collections
 	if len(cardLists) < 1 {
 		return nil, errors.New("no decks found in the mtg logs. make sure to enable logs in the Arena app")
 	}
@@ -194,26 +312,51 @@ func newDeckHelper(mtgOutputLogPath string, mtgDataPath string, enabledSets stri
 	log.Printf("Collection has %d cards", len(collection))
 
 	log.Println("Parsing MTG Data Files...")
-	db, err := carddb.CreateLibrary(mtgDataPath)
+	db, err := carddb.CreateCachedLibrary(mtgDataPath, cacheDir, lang)
 	if err != nil {
 		return nil, fmt.Errorf("createLibrary failed: %v", err)
 	}
 
-	enabledExpansions, err := parseExpansions(enabledSets)
+	collection = carddb.CollapseFaces(db, collection)
+
+	setsData, err := sets.LoadData(setsDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sets data: %v", err)
+	}
+	enabledExpansions, err := parseExpansions(sets.Discover(db, setsData), enabledSets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse enabled expansions list: %v", err)
 	}
 
-	return &deckHelper{enabledExpansions, db, collection}, nil
+	return &deckHelper{enabledExpansions, db, collection, mtgaLog.Decks}, nil
 }
 
 func main() {
 	flag.Parse()
-	helper, err := newDeckHelper(*mtgOutputLog, *mtgDataPath, *enabledSets)
+	helper, err := newDeckHelper(*mtgOutputLog, *mtgDataPath, *cacheDir, *setsData, *enabledSets, *language)
 	if err != nil {
 		log.Fatalf("failed to create deck helper: %v", err)
 	}
 
+	if *allSaved || *savedName != "" {
+		decks, err := helper.findSavedDecks(*savedName)
+		if err != nil {
+			log.Fatalf("failed to find saved deck: %v", err)
+		}
+		for _, d := range decks {
+			deck, err := helper.savedDeckCards(d)
+			if err != nil {
+				log.Fatalf("failed to read saved deck: %v", err)
+			}
+			fmt.Printf("== %s (%s) ==\n", d.Name, d.Format)
+			if err := helper.printDeckReport(deck); err != nil {
+				// Saved decks might use cards from sets that are not enabled.
+				fmt.Printf("Can't build deck: %v\n", err)
+			}
+		}
+		return
+	}
+
 	var deckReader io.Reader
 	if !*fromClipboard {
 		deckFile, err := os.Open(*deckPath)
@@ -234,10 +377,17 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to parse deck file: %v", err)
 	}
+	if err := helper.printDeckReport(deck); err != nil {
+		log.Fatalf("failed to get deck distance: %v", err)
+	}
+}
 
+// printDeckReport prints the cards that have to be crafted to build deck, and its tokens if -tokens
+// is set.
+func (helper deckHelper) printDeckReport(deck []card) error {
 	dist, err := helper.deckDistance(deck)
 	if err != nil {
-		log.Fatalf("failed to get deck distance: %v", err)
+		return err
 	}
 
 	totalCount := uint32(0)
@@ -245,7 +395,7 @@ func main() {
 	for id, count := range dist {
 		card := helper.db.GetCardByID(id)
 		if card == nil {
-			log.Fatalf("invalid card id %d", id)
+			return fmt.Errorf("invalid card id %d", id)
 		}
 		byRarity[card.Rarity] += count
 		fmt.Printf("%d %s (%s)\n", count, card.Name, cardRarity[card.Rarity])
@@ -255,4 +405,18 @@ func main() {
 	for rarity, count := range byRarity {
 		fmt.Printf("%s: %d\n", cardRarity[rarity], count)
 	}
+
+	if *listTokens {
+		tokens := helper.deckTokens(deck)
+		var names []string
+		for name := range tokens {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("Tokens:\n")
+		for _, name := range names {
+			fmt.Printf("%s (%s)\n", name, strings.Join(tokens[name], ", "))
+		}
+	}
+	return nil
 }
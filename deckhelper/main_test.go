@@ -3,8 +3,13 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+	"github.com/mvanotti/mtgassistant/carddb/carddbtest"
+	"github.com/mvanotti/mtgassistant/collectionfinder"
 )
 
 func TestParseDecks(t *testing.T) {
@@ -51,3 +56,140 @@ func TestParseIgnoredWords(t *testing.T) {
 		t.Fatalf("Wrong amount of cards. want 2, got %d", len(cards))
 	}
 }
+
+func TestParseLocalizedDeck(t *testing.T) {
+	for _, deck := range []string{`
+	Compañero
+	1 Lurrus de la Cueva de Pesadilla (IKO) 226
+
+	Mazo
+	4 Elfos de Llanowar (DAR) 168
+	20 Bosque (M19) 277
+
+	Banquillo
+	2 Bosque (M19) 277
+	`, `
+	Companheiro
+	1 Lurrus do Covil Pesadelo (IKO) 226
+
+	Baralho
+	4 Elfos de Llanowar (DAR) 168
+	20 Floresta (M19) 277
+
+	Reserva
+	2 Floresta (M19) 277
+	`} {
+		cards, err := parseDeck(strings.NewReader(deck))
+		if err != nil {
+			t.Errorf("failed to parse deck: %v", err)
+			continue
+		}
+		if len(cards) != 4 {
+			t.Errorf("wrong amount of cards. want 4, got %d: %+v", len(cards), cards)
+		}
+	}
+
+	if _, err := parseDeck(strings.NewReader("4 Llanowar Elves")); err == nil {
+		t.Errorf("parseDeck succeeded for a card without a set")
+	}
+}
+
+func testHelper(t *testing.T, collection map[uint64]uint32) *deckHelper {
+	b := carddbtest.New()
+	b.AddCard("Llanowar Elves").GrpID(1).Set("M19", "314").Cost("{G}").Types("Creature — Elf Druid")
+	b.AddCard("Llanowar Elves").GrpID(2).Set("DAR", "168").Cost("{G}").Types("Creature — Elf Druid")
+	food := b.AddCard("Food").GrpID(3).Set("ELD", "T15").Types("Artifact — Food").Token()
+	b.AddCard("Bake into a Pie").GrpID(4).Set("ELD", "76").Rarity(carddb.CommonRarity).Cost("2BB").Types("Instant").CreatesToken(food)
+	b.AddCard("Gilded Goose").GrpID(5).Set("ELD", "160").Rarity(carddb.RareRarity).Cost("G").Types("Creature — Bird").CreatesToken(food)
+	b.AddCard("Forest").GrpID(6).Set("M19", "277").Rarity(carddb.BasicLandRarity).Types("Basic Land — Forest").Localized("es-ES", "Bosque")
+	db, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build card database: %v", err)
+	}
+	enabled := map[string]bool{"M19": true, "DAR": false, "ELD": true}
+	return &deckHelper{enabled, db, collection, nil}
+}
+
+func TestDeckDistance(t *testing.T) {
+	helper := testHelper(t, map[uint64]uint32{1: 2, 4: 4})
+	deck, err := parseDeck(strings.NewReader(`
+4 Llanowar Elves (DAR) 168
+2 Bake into a Pie (ELD) 76
+4 Gilded Goose (ELD) 160
+10 Bosque (M19) 277
+`))
+	if err != nil {
+		t.Fatalf("failed to parse deck: %v", err)
+	}
+	got, err := helper.deckDistance(deck)
+	if err != nil {
+		t.Fatalf("deckDistance failed: %v", err)
+	}
+	// DAR is not enabled, so the elves have to be crafted from M19.
+	want := map[uint64]uint32{1: 2, 5: 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deckDistance mismatch. want %v, got %v", want, got)
+	}
+
+	deck = []card{{count: 1, name: "Llanowar Elf", expn: "M19", cc: "999"}}
+	if _, err := helper.deckDistance(deck); err == nil || !strings.Contains(err.Error(), `"Llanowar Elves"`) {
+		t.Errorf("deckDistance with an unknown card should suggest Llanowar Elves, got %v", err)
+	}
+}
+
+func TestDeckTokens(t *testing.T) {
+	helper := testHelper(t, nil)
+	deck := []card{
+		{count: 4, name: "Gilded Goose", expn: "ELD", cc: "160"},
+		{count: 2, name: "Bake into a Pie", expn: "ELD", cc: "76"},
+		{count: 4, name: "Llanowar Elves", expn: "M19", cc: "314"},
+		{count: 1, name: "Gilded Goose", expn: "ELD", cc: "160"},
+	}
+	want := map[string][]string{"Food": {"Gilded Goose", "Bake into a Pie"}}
+	if got := helper.deckTokens(deck); !reflect.DeepEqual(got, want) {
+		t.Errorf("deckTokens mismatch. want %v, got %v", want, got)
+	}
+}
+
+func TestSavedDecks(t *testing.T) {
+	helper := testHelper(t, map[uint64]uint32{1: 4})
+	helper.savedDecks = []collectionfinder.Deck{
+		{Name: "Food", MainDeck: map[uint64]uint32{5: 4, 4: 2, 6: 16}, Sideboard: map[uint64]uint32{4: 1}, Companion: map[uint64]uint32{5: 1}},
+		{Name: "Elves", MainDeck: map[uint64]uint32{1: 4}},
+	}
+
+	decks, err := helper.findSavedDecks("food")
+	if err != nil || len(decks) != 1 || decks[0].Name != "Food" {
+		t.Fatalf("findSavedDecks(food) = %v, %v", decks, err)
+	}
+	deck, err := helper.savedDeckCards(decks[0])
+	if err != nil {
+		t.Fatalf("savedDeckCards failed: %v", err)
+	}
+	want := []card{
+		{count: 3, name: "Bake into a Pie", expn: "ELD", cc: "76"},
+		{count: 4, name: "Gilded Goose", expn: "ELD", cc: "160"},
+		{count: 16, name: "Forest", expn: "M19", cc: "277"},
+	}
+	if !reflect.DeepEqual(deck, want) {
+		t.Errorf("savedDeckCards mismatch. want %+v, got %+v", want, deck)
+	}
+	got, err := helper.deckDistance(deck)
+	if err != nil {
+		t.Fatalf("deckDistance failed: %v", err)
+	}
+	if want := map[uint64]uint32{4: 3, 5: 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deckDistance mismatch. want %v, got %v", want, got)
+	}
+
+	if decks, err := helper.findSavedDecks(""); err != nil || len(decks) != 2 {
+		t.Errorf("findSavedDecks() should return all decks, got %v, %v", decks, err)
+	}
+	if _, err := helper.findSavedDecks("Goblins"); err == nil || !strings.Contains(err.Error(), `"Elves"`) {
+		t.Errorf("findSavedDecks(Goblins) should list the saved decks, got %v", err)
+	}
+	helper.savedDecks[1].MainDeck[99] = 1
+	if _, err := helper.savedDeckCards(helper.savedDecks[1]); err == nil {
+		t.Errorf("savedDeckCards should fail for unknown cards")
+	}
+}
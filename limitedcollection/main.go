@@ -13,8 +13,9 @@ import (
 )
 
 var (
-	mtgOutputLog = flag.String("log_file", `${USERPROFILE}\AppData\LocalLow\Wizards Of The Coast\MTGA\output_log.txt`, "Filepath of the MTG Arena Output Log, typically stored in an MTG folder inside C:\\Users")
+	mtgOutputLog = flag.String("log_file", collectionfinder.DefaultLogFile(), "Filepath of the MTG Arena log (Player.log, or output_log.txt in old versions), typically stored in an MTG folder inside C:\\Users")
 	mtgDataPath  = flag.String("mtg_data", `C:\Program Files (x86)\Wizards of the Coast\MTGA\MTGA_Data\Downloads\Data`, "Path to the Downloads\\Data folder inside the MTG Arena Install Directory")
+	cacheDir     = flag.String("cache_dir", carddb.DefaultCacheDir(), "Directory for the card database cache. Empty disables the cache.")
 	diffStart    = flag.Int("diff_start", 0, "Starting diff point")
 	diffEnd      = flag.Int("diff_end", 1, "Last diff message")
 )
@@ -27,13 +28,16 @@ func main() {
 		log.Fatalf("failed to open log file: %v", err)
 	}
 	defer f.Close()
-	boosterData, err := collectionfinder.FindBoosters(f)
+	boosterData, diags, err := collectionfinder.FindBoostersWithOptions(f, collectionfinder.ParseOptions{})
 	if err != nil {
 		log.Fatalf("failed to parse mtga logs: %v", err)
 	}
+	if len(diags) > 0 {
+		log.Printf("Skipped %d messages that couldn't be decoded", len(diags))
+	}
 
 	log.Println("Parsing MTG Data Files...")
-	db, err := carddb.CreateLibrary(*mtgDataPath)
+	db, err := carddb.CreateCachedLibrary(*mtgDataPath, *cacheDir, "en-US")
 	if err != nil {
 		log.Fatalf("createLibrary failed: %v", err)
 	}
@@ -43,6 +47,10 @@ func main() {
 
 		for _, id := range booster.CardIds {
 			card := db.GetCardByID(id)
+			if card == nil {
+				fmt.Printf("1 Unknown card #%d\n", id)
+				continue
+			}
 			fmt.Printf("%d %s (%s) %s\n", 1, card.Name, card.Set, card.CollectorNumber)
 		}
 
@@ -0,0 +1,184 @@
+// program matchhistory parses a "Magic The Gathering - Arena" output log and prints the win rate of the matches played,
+// by deck and by event.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/mvanotti/mtgassistant/collectionfinder"
+)
+
+var (
+	mtgOutputLog = flag.String("log_file", collectionfinder.DefaultLogFile(), "Filepath of the MTG Arena log (Player.log, or output_log.txt in old versions), typically stored in an MTG folder inside C:\\Users")
+	listMatches  = flag.Bool("matches", false, "Also print every match.")
+)
+
+// record counts the wins, losses and draws of a group of matches. The games on the play and on the
+// draw are counted separately, as the win rate usually differs. Games where it is not known who went
+// first are not in those counts.
+type record struct {
+	Name    string
+	Matches gamesRecord
+	OnPlay  gamesRecord
+	OnDraw  gamesRecord
+}
+
+// gamesRecord counts the results of a group of games or matches.
+type gamesRecord struct {
+	Wins, Losses, Draws int
+}
+
+func (r gamesRecord) total() int {
+	return r.Wins + r.Losses + r.Draws
+}
+
+func (r *gamesRecord) add(won, drawn bool) {
+	switch {
+	case drawn:
+		r.Draws++
+	case won:
+		r.Wins++
+	default:
+		r.Losses++
+	}
+}
+
+func (r gamesRecord) String() string {
+	rate := 0.0
+	if r.total() > 0 {
+		rate = 100 * float64(r.Wins) / float64(r.total())
+	}
+	return fmt.Sprintf("%d-%d-%d (%.1f%%)", r.Wins, r.Losses, r.Draws, rate)
+}
+
+func (r *record) add(m *collectionfinder.MatchRecord) {
+	r.Matches.add(m.Won, m.Drawn)
+	for _, g := range m.Games {
+		switch {
+		case !g.PlayKnown:
+			continue
+		case g.OnPlay:
+			r.OnPlay.add(g.Won, g.Drawn)
+		default:
+			r.OnDraw.add(g.Won, g.Drawn)
+		}
+	}
+}
+
+func (r *record) String() string {
+	return fmt.Sprintf("%s: %v, on the play %v, on the draw %v", r.Name, r.Matches, r.OnPlay, r.OnDraw)
+}
+
+// summarize groups the matches by key, and returns the record of each group, sorted by number of
+// matches and then by name.
+func summarize(matches []collectionfinder.MatchRecord, key func(*collectionfinder.MatchRecord) string) []record {
+	groups := make(map[string]*record)
+	for i := range matches {
+		name := key(&matches[i])
+		r, ok := groups[name]
+		if !ok {
+			r = &record{Name: name}
+			groups[name] = r
+		}
+		r.add(&matches[i])
+	}
+	res := make([]record, 0, len(groups))
+	for _, r := range groups {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if n, m := res[i].Matches.total(), res[j].Matches.total(); n != m {
+			return n > m
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+func deckName(m *collectionfinder.MatchRecord) string {
+	if m.DeckName == "" {
+		return "(unknown deck)"
+	}
+	return m.DeckName
+}
+
+func eventName(m *collectionfinder.MatchRecord) string {
+	if m.EventName == "" {
+		return "(unknown event)"
+	}
+	return m.EventName
+}
+
+func printMatch(w io.Writer, m *collectionfinder.MatchRecord) {
+	result := "Lost"
+	switch {
+	case m.Drawn:
+		result = "Draw"
+	case m.Won:
+		result = "Won"
+	}
+	games := ""
+	for _, g := range m.Games {
+		switch {
+		case g.Drawn:
+			games += "D"
+		case g.Won:
+			games += "W"
+		default:
+			games += "L"
+		}
+	}
+	opponent := m.Opponent
+	if m.OpponentRank != "" {
+		opponent += " (" + m.OpponentRank + ")"
+	}
+	fmt.Fprintf(w, "%s %s vs %s with %s in %s: %s %s (%v)\n", m.Start.Format("2006-01-02 15:04"), m.MatchID, opponent,
+		deckName(m), eventName(m), result, games, m.Duration())
+}
+
+func printHistory(w io.Writer, matches []collectionfinder.MatchRecord) {
+	total := record{Name: "Total"}
+	for i := range matches {
+		total.add(&matches[i])
+	}
+	fmt.Fprintf(w, "%s\n", &total)
+	fmt.Fprintf(w, "\nBy deck:\n")
+	for _, r := range summarize(matches, deckName) {
+		fmt.Fprintf(w, "%s\n", &r)
+	}
+	fmt.Fprintf(w, "\nBy event:\n")
+	for _, r := range summarize(matches, eventName) {
+		fmt.Fprintf(w, "%s\n", &r)
+	}
+}
+
+func main() {
+	flag.Parse()
+	f, err := os.Open(os.ExpandEnv(*mtgOutputLog))
+	if err != nil {
+		log.Fatalf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+	matches, diags, err := collectionfinder.FindMatchesWithOptions(f, collectionfinder.ParseOptions{})
+	if err != nil {
+		log.Fatalf("failed to parse mtga logs: %v", err)
+	}
+	if len(diags) > 0 {
+		log.Printf("Skipped %d messages that couldn't be decoded", len(diags))
+	}
+	if len(matches) == 0 {
+		log.Fatal("no matches found in the mtg logs. make sure to enable logs in the Arena app.")
+	}
+	if *listMatches {
+		for i := range matches {
+			printMatch(os.Stdout, &matches[i])
+		}
+		fmt.Println()
+	}
+	printHistory(os.Stdout, matches)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mvanotti/mtgassistant/collectionfinder"
+)
+
+func TestSummarize(t *testing.T) {
+	matches := []collectionfinder.MatchRecord{
+		{EventName: "Ladder", DeckName: "Mono Green", Won: true, Games: []collectionfinder.GameResult{{Won: true, OnPlay: true, PlayKnown: true}}},
+		{EventName: "Ladder", DeckName: "Azorius", Won: false, Games: []collectionfinder.GameResult{{Won: false, OnPlay: false, PlayKnown: true}}},
+		{EventName: "Traditional_Ladder", DeckName: "Mono Green", Won: true, Games: []collectionfinder.GameResult{
+			{Won: false, OnPlay: true, PlayKnown: true}, {Won: true, OnPlay: false, PlayKnown: true}, {Won: true, OnPlay: false, PlayKnown: true}}},
+		// Who went first is not known, and the game doesn't count for the play or the draw.
+		{EventName: "Ladder", Won: false, Games: []collectionfinder.GameResult{{Won: false}}},
+		{EventName: "Ladder", DeckName: "Azorius", Drawn: true, Games: []collectionfinder.GameResult{{Drawn: true, OnPlay: true, PlayKnown: true}}},
+	}
+	want := []record{
+		{Name: "Azorius", Matches: gamesRecord{Losses: 1, Draws: 1}, OnPlay: gamesRecord{Draws: 1}, OnDraw: gamesRecord{Losses: 1}},
+		{Name: "Mono Green", Matches: gamesRecord{Wins: 2}, OnPlay: gamesRecord{Wins: 1, Losses: 1}, OnDraw: gamesRecord{Wins: 2}},
+		{Name: "(unknown deck)", Matches: gamesRecord{Losses: 1}},
+	}
+	if got := summarize(matches, deckName); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong summary by deck.\nwant %+v\ngot  %+v", want, got)
+	}
+	want = []record{
+		{Name: "Ladder", Matches: gamesRecord{Wins: 1, Losses: 2, Draws: 1}, OnPlay: gamesRecord{Wins: 1, Draws: 1}, OnDraw: gamesRecord{Losses: 1}},
+		{Name: "Traditional_Ladder", Matches: gamesRecord{Wins: 1}, OnPlay: gamesRecord{Losses: 1}, OnDraw: gamesRecord{Wins: 2}},
+	}
+	if got := summarize(matches, eventName); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong summary by event.\nwant %+v\ngot  %+v", want, got)
+	}
+
+	var b bytes.Buffer
+	printHistory(&b, matches)
+	if !strings.HasPrefix(b.String(), "Total: 2-2-1 (40.0%), on the play 1-1-1 (33.3%), on the draw 2-1-0 (66.7%)\n") {
+		t.Errorf("wrong history:\n%s", b.String())
+	}
+}
@@ -0,0 +1,206 @@
+// Package sets describes the Magic The Gathering: Arena sets, their release order and the formats they
+// are legal in. The sets are discovered from a card database, and the release dates and formats come
+// from a data file that can be updated without recompiling. A default data file is embedded in the package.
+package sets
+
+import (
+	"bytes"
+	_ "embed" // for the default data file.
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+)
+
+//go:embed sets.json
+var defaultData []byte
+
+// dataVersion is the version of the data file format supported by this package.
+const dataVersion = 1
+
+// Data is the contents of a sets data file.
+type Data struct {
+	Version int          `json:"version"`
+	Updated string       `json:"updated"`
+	Sets    []SetData    `json:"sets"`
+	Formats []FormatData `json:"formats"`
+}
+
+// SetData is the information about a set in the data file.
+type SetData struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Released string `json:"released"` // YYYY-MM-DD
+}
+
+// FormatData describes which sets are legal in a format.
+type FormatData struct {
+	Name string   `json:"name"`
+	Sets []string `json:"sets"`
+	// AllSets means that every set in the card database is legal in the format.
+	AllSets bool `json:"allSets"`
+}
+
+// ParseData parses a sets data file.
+func ParseData(r io.Reader) (*Data, error) {
+	var data Data
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode sets data: %v", err)
+	}
+	if data.Version != dataVersion {
+		return nil, fmt.Errorf("unsupported sets data version %d, want %d", data.Version, dataVersion)
+	}
+	for _, s := range data.Sets {
+		if s.Released == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", s.Released); err != nil {
+			return nil, fmt.Errorf("invalid release date for set %s: %v", s.Code, err)
+		}
+	}
+	return &data, nil
+}
+
+// LoadData reads the sets data file at path. An empty path returns the default data.
+func LoadData(path string) (*Data, error) {
+	if path == "" {
+		return DefaultData(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sets data file: %v", err)
+	}
+	defer f.Close()
+	return ParseData(f)
+}
+
+// DefaultData returns the data file embedded in the package.
+func DefaultData() *Data {
+	data, err := ParseData(bytes.NewReader(defaultData))
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded sets data: %v", err))
+	}
+	return data
+}
+
+// Set is a Magic The Gathering: Arena set.
+type Set struct {
+	Code string
+	// Name is the name of the set, or the code if the set is not in the data file.
+	Name string
+	// Released is the release date of the set, zero if the set is not in the data file.
+	Released time.Time
+	// Order is the position of the set in the release order, starting from 0. Sets without a
+	// release date go last.
+	Order int
+	// Formats are the names of the formats the set is legal in.
+	Formats []string
+	// Cards is the number of collectible cards in the set.
+	Cards int
+}
+
+// Catalog has all the sets in a card database.
+type Catalog struct {
+	sets    []*Set
+	byCode  map[string]*Set // upper case code.
+	formats []string
+	// formatSets has the set codes for each format, keyed by the lower case format name.
+	formatSets map[string][]string
+}
+
+// Discover creates a Catalog with every set in db, using data for the release dates and formats.
+func Discover(db carddb.CardDB, data *Data) *Catalog {
+	cat := &Catalog{
+		byCode:     make(map[string]*Set),
+		formatSets: make(map[string][]string),
+	}
+
+	db.ForEach(func(c carddb.Card) {
+		if c.Set == "" {
+			return
+		}
+		code := strings.ToUpper(c.Set)
+		s, ok := cat.byCode[code]
+		if !ok {
+			s = &Set{Code: c.Set, Name: c.Set}
+			cat.byCode[code] = s
+			cat.sets = append(cat.sets, s)
+		}
+		if c.IsCollectible {
+			s.Cards++
+		}
+	})
+
+	for _, sd := range data.Sets {
+		s, ok := cat.byCode[strings.ToUpper(sd.Code)]
+		if !ok {
+			continue
+		}
+		s.Name = sd.Name
+		s.Released, _ = time.Parse("2006-01-02", sd.Released)
+	}
+
+	sort.Slice(cat.sets, func(i, j int) bool {
+		a, b := cat.sets[i], cat.sets[j]
+		if a.Released.IsZero() != b.Released.IsZero() {
+			return !a.Released.IsZero()
+		}
+		if !a.Released.Equal(b.Released) {
+			return a.Released.Before(b.Released)
+		}
+		return a.Code < b.Code
+	})
+	for i, s := range cat.sets {
+		s.Order = i
+	}
+
+	for _, f := range data.Formats {
+		var codes []string
+		if f.AllSets {
+			for _, s := range cat.sets {
+				codes = append(codes, s.Code)
+			}
+		} else {
+			for _, code := range f.Sets {
+				if s, ok := cat.byCode[strings.ToUpper(code)]; ok {
+					codes = append(codes, s.Code)
+				}
+			}
+		}
+		for _, code := range codes {
+			s := cat.byCode[strings.ToUpper(code)]
+			s.Formats = append(s.Formats, f.Name)
+		}
+		cat.formats = append(cat.formats, f.Name)
+		cat.formatSets[strings.ToLower(f.Name)] = codes
+	}
+
+	return cat
+}
+
+// Sets returns all the sets, in release order.
+func (cat *Catalog) Sets() []*Set {
+	return cat.sets
+}
+
+// Set returns the set with the given code. The code is case insensitive.
+func (cat *Catalog) Set(code string) (*Set, bool) {
+	s, ok := cat.byCode[strings.ToUpper(code)]
+	return s, ok
+}
+
+// Formats returns the names of all the formats.
+func (cat *Catalog) Formats() []string {
+	return cat.formats
+}
+
+// Format returns the codes of the sets that are legal in the given format. The name is case insensitive.
+func (cat *Catalog) Format(name string) ([]string, bool) {
+	codes, ok := cat.formatSets[strings.ToLower(name)]
+	return codes, ok
+}
@@ -0,0 +1,89 @@
+package sets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+)
+
+var testData = `{
+	"version": 1,
+	"sets": [
+		{"code": "ELD", "name": "Throne of Eldraine", "released": "2019-10-04"},
+		{"code": "THB", "name": "Theros Beyond Death", "released": "2020-01-24"},
+		{"code": "M20", "name": "Core Set 2020", "released": "2019-07-12"},
+		{"code": "ZZZ", "name": "Not in the database", "released": "2030-01-01"}
+	],
+	"formats": [
+		{"name": "Standard", "sets": ["THB", "ELD", "ZZZ"]},
+		{"name": "Historic", "allSets": true}
+	]
+}`
+
+func testDB(t *testing.T) carddb.CardDB {
+	cardsJSON := `[
+		{"grpid": 1, "titleId": 1, "set": "THB", "isCollectible": true},
+		{"grpid": 2, "titleId": 1, "set": "ELD", "isCollectible": true},
+		{"grpid": 3, "titleId": 1, "set": "ELD", "isCollectible": true},
+		{"grpid": 4, "titleId": 1, "set": "M20", "isCollectible": true},
+		{"grpid": 5, "titleId": 1, "set": "ArenaSUP", "isCollectible": false}
+	]`
+	textsJSON := `[{"isoCode": "en-US", "keys": [{"id": 1, "text": "Card"}]}]`
+	db, err := carddb.NewLibrary(strings.NewReader(cardsJSON), strings.NewReader(textsJSON), "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+	return db
+}
+
+func TestDiscover(t *testing.T) {
+	data, err := ParseData(strings.NewReader(testData))
+	if err != nil {
+		t.Fatalf("failed to parse data: %v", err)
+	}
+	cat := Discover(testDB(t), data)
+
+	var order []string
+	for _, s := range cat.Sets() {
+		order = append(order, s.Code)
+	}
+	if got, want := strings.Join(order, ","), "M20,ELD,THB,ArenaSUP"; got != want {
+		t.Errorf("wrong set order. want %s, got %s", want, got)
+	}
+
+	eld, ok := cat.Set("eld")
+	if !ok {
+		t.Fatalf("set ELD not found")
+	}
+	if eld.Name != "Throne of Eldraine" || eld.Cards != 2 || eld.Released.Year() != 2019 {
+		t.Errorf("wrong set data for ELD: %+v", eld)
+	}
+	if strings.Join(eld.Formats, ",") != "Standard,Historic" {
+		t.Errorf("wrong formats for ELD: %v", eld.Formats)
+	}
+
+	std, ok := cat.Format("standard")
+	if !ok || strings.Join(std, ",") != "THB,ELD" {
+		t.Errorf("wrong Standard sets: %v", std)
+	}
+	if historic, _ := cat.Format("Historic"); len(historic) != 4 {
+		t.Errorf("wrong Historic sets: %v", historic)
+	}
+	if _, ok := cat.Set("ZZZ"); ok {
+		t.Errorf("set ZZZ is not in the database, but was found")
+	}
+}
+
+func TestDefaultData(t *testing.T) {
+	data := DefaultData()
+	if len(data.Sets) == 0 || len(data.Formats) == 0 {
+		t.Errorf("default data is empty: %+v", data)
+	}
+}
+
+func TestParseDataVersion(t *testing.T) {
+	if _, err := ParseData(strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Errorf("ParseData should fail with an unsupported version")
+	}
+}
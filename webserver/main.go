@@ -2,12 +2,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/mvanotti/mtgassistant/carddb"
 	"github.com/mvanotti/mtgassistant/collectionfinder"
@@ -15,8 +17,10 @@ import (
 
 var (
 	mtgDataPath = flag.String("mtg_data", `C:\Program Files (x86)\Wizards of the Coast\MTGA\MTGA_Data\Downloads\Data`, "Path to the Downloads\\Data folder inside the MTG Arena Install Directory")
+	cacheDir    = flag.String("cache_dir", carddb.DefaultCacheDir(), "Directory for the card database cache. Empty disables the cache.")
 	landingpage = flag.String("landing", "boostertracking.html", "Path to the landing page.")
 	jsonFormat  = flag.Bool("json", true, "Whether or not to output booster info in JSON format.")
+	reloadEvery = flag.Duration("reload_interval", time.Minute, "How often to check the Data folder for new card data. 0 disables reloading.")
 )
 
 const maxMtgaLogsSize int64 = 100 << 20 // 20 MiB
@@ -30,7 +34,17 @@ type BoosterContents struct {
 	Cards      []string `json:"cards"`
 }
 
-func outputJSON(w http.ResponseWriter, boosterData []collectionfinder.BoosterContents) {
+// boosterCard returns the line for a card opened in a booster. Cards that are not in the database, which
+// might be older than the logs or not have every card, are shown by ID.
+func boosterCard(dc carddb.CardDB, id uint64) string {
+	card := dc.GetCardByID(id)
+	if card == nil {
+		return fmt.Sprintf("1 Unknown card #%d", id)
+	}
+	return fmt.Sprintf("%d %s (%s) %s", 1, card.Name, card.Set, card.CollectorNumber)
+}
+
+func outputJSON(w http.ResponseWriter, dc carddb.CardDB, boosterData []collectionfinder.BoosterContents) {
 	var boosters []BoosterContents
 
 	w.Header().Add("Content-Type", "application/json")
@@ -42,9 +56,7 @@ func outputJSON(w http.ResponseWriter, boosterData []collectionfinder.BoosterCon
 		contents.WcRare = booster.RareWildcards
 		contents.WcMythic = booster.MythicWildcards
 		for _, id := range booster.CardIds {
-			card := db.GetCardByID(id)
-			c := fmt.Sprintf("%d %s (%s) %s", 1, card.Name, card.Set, card.CollectorNumber)
-			contents.Cards = append(contents.Cards, c)
+			contents.Cards = append(contents.Cards, boosterCard(dc, id))
 		}
 		boosters = append(boosters, contents)
 	}
@@ -53,15 +65,14 @@ func outputJSON(w http.ResponseWriter, boosterData []collectionfinder.BoosterCon
 	enc.Encode(boosters)
 }
 
-func outputPlain(w http.ResponseWriter, boosterData []collectionfinder.BoosterContents) {
+func outputPlain(w http.ResponseWriter, dc carddb.CardDB, boosterData []collectionfinder.BoosterContents) {
 	w.Header().Add("Content-Type", "text/plain")
 
 	for i, booster := range boosterData {
 		fmt.Fprintf(w, "Booster #%d\n", i)
 
 		for _, id := range booster.CardIds {
-			card := db.GetCardByID(id)
-			fmt.Fprintf(w, "%d %s (%s) %s\n", 1, card.Name, card.Set, card.CollectorNumber)
+			fmt.Fprintln(w, boosterCard(dc, id))
 		}
 
 		fmt.Fprintf(w, "\nCommon Wildcards: %d\nUncommon Wildcards: %d\nRare Wildcards: %d\nMythic Wildcards: %d\n",
@@ -74,6 +85,7 @@ func uploadHandler(dc carddb.CardDB, jsonFormat bool) func(w http.ResponseWriter
 		if err := r.ParseMultipartForm(maxMtgaLogsSize); err != nil {
 			log.Printf("could not parse multipart form: %v", err)
 			http.Error(w, "Invalid Request", http.StatusPreconditionFailed)
+			return
 		}
 
 		file, _, err := r.FormFile("mtgalogs")
@@ -83,15 +95,47 @@ func uploadHandler(dc carddb.CardDB, jsonFormat bool) func(w http.ResponseWriter
 			return
 		}
 		defer file.Close()
-		boosterData, err := collectionfinder.FindBoosters(file)
+		boosterData, diags, err := collectionfinder.FindBoostersWithOptions(file, collectionfinder.ParseOptions{})
 		if err != nil {
-			log.Fatalf("failed to parse mtga logs: %v", err)
+			log.Printf("failed to parse mtga logs: %v", err)
+			http.Error(w, "Could not parse mtg logs file", http.StatusBadRequest)
+			return
+		}
+		if len(diags) > 0 {
+			log.Printf("Skipped %d messages that couldn't be decoded in the uploaded logs", len(diags))
 		}
 		if jsonFormat {
-			outputJSON(w, boosterData)
+			outputJSON(w, dc, boosterData)
 		} else {
-			outputPlain(w, boosterData)
+			outputPlain(w, dc, boosterData)
+		}
+	}
+}
+
+func searchHandler(dc carddb.CardDB) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cards, err := dc.Query(r.FormValue("q"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res := make([]string, 0, len(cards))
+		for _, card := range cards {
+			res = append(res, fmt.Sprintf("%s (%s) %s", card.Name, card.Set, card.CollectorNumber))
 		}
+
+		w.Header().Add("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.Encode(res)
+	}
+}
+
+func statusHandler(l *carddb.ReloadingLibrary) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.Encode(l.Status())
 	}
 }
 
@@ -101,8 +145,6 @@ func boosterTracker(landingpagedata []byte) func(w http.ResponseWriter, r *http.
 	}
 }
 
-var db carddb.CardDB
-
 func main() {
 	flag.Parse()
 
@@ -113,13 +155,18 @@ func main() {
 		log.Fatalf("failed to parse landing page file: %v", err)
 	}
 
-	db, err = carddb.CreateLibrary(*mtgDataPath)
+	library, err := carddb.NewReloadingLibrary(*mtgDataPath, *cacheDir, "en-US")
 	if err != nil {
 		log.Fatalf("createLibrary failed: %v", err)
 	}
+	if *reloadEvery > 0 {
+		go library.Watch(context.Background(), *reloadEvery)
+	}
 
 	log.Println("Starting Server")
-	http.HandleFunc("/upload", uploadHandler(db, *jsonFormat))
+	http.HandleFunc("/upload", uploadHandler(library, *jsonFormat))
 	http.HandleFunc("/boostertracking", boosterTracker(landingpagedata))
+	http.HandleFunc("/search", searchHandler(library))
+	http.HandleFunc("/status", statusHandler(library))
 	log.Fatal(http.ListenAndServe("127.0.0.1:8080", nil))
 }
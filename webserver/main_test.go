@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mvanotti/mtgassistant/carddb"
+	"github.com/mvanotti/mtgassistant/carddb/carddbtest"
+)
+
+func testBuilder() *carddbtest.Builder {
+	b := carddbtest.New()
+	b.AddCard("Llanowar Elves").GrpID(1).Set("M19", "314").Cost("{G}").Types("Creature — Elf Druid")
+	b.AddCard("Shock").GrpID(2).Set("M19", "156").Cost("{R}").Types("Instant")
+	b.AddCard("Lyra Dawnbringer").GrpID(3).Set("DOM", "26").Rarity(carddb.MythicRarity).Cost("3WW").Types("Legendary Creature — Angel")
+	return b
+}
+
+func testDB(t *testing.T) carddb.CardDB {
+	db, err := testBuilder().Build()
+	if err != nil {
+		t.Fatalf("failed to build card database: %v", err)
+	}
+	return db
+}
+
+const boosterLog = `[UnityCrossThreadLogger]<== Inventory.Updated {"id": 1, "payload": {"context": "Booster.Open", "updates": [
+	{"delta": {"wcRareDelta": 1}, "aetherizedCards": [{"grpId": 1}, {"grpId": 3}, {"grpId": 99}]}]}}
+[UnityCrossThreadLogger]<== Inventory.Updated {"id": 2, "payload": {"context": "Quest.Completed", "updates": []}}
+`
+
+func uploadRequest(t *testing.T, logs string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("mtgalogs", "output_log.txt")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	fw.Write([]byte(logs))
+	mw.Close()
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadHandler(t *testing.T) {
+	db := testDB(t)
+
+	w := httptest.NewRecorder()
+	uploadHandler(db, true)(w, uploadRequest(t, boosterLog))
+	var boosters []BoosterContents
+	if err := json.NewDecoder(w.Body).Decode(&boosters); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []BoosterContents{{WcRare: 1, Cards: []string{"1 Llanowar Elves (M19) 314", "1 Lyra Dawnbringer (DOM) 26", "1 Unknown card #99"}}}
+	if !reflect.DeepEqual(boosters, want) {
+		t.Errorf("upload mismatch. want %+v, got %+v", want, boosters)
+	}
+
+	w = httptest.NewRecorder()
+	uploadHandler(db, false)(w, uploadRequest(t, boosterLog))
+	if got := w.Body.String(); !strings.Contains(got, "1 Lyra Dawnbringer (DOM) 26\n") || !strings.Contains(got, "1 Unknown card #99\n") ||
+		!strings.Contains(got, "Rare Wildcards: 1\n") {
+		t.Errorf("wrong plain output: %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	uploadHandler(db, true)(w, httptest.NewRequest("POST", "/upload", nil))
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("upload without a form. want status %d, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	db := testDB(t)
+
+	w := httptest.NewRecorder()
+	searchHandler(db)(w, httptest.NewRequest("GET", "/search?q=set:M19+t:creature", nil))
+	var got []string
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := []string{"Llanowar Elves (M19) 314"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search mismatch. want %v, got %v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	searchHandler(db)(w, httptest.NewRequest("GET", "/search?q=r:legendary", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid query. want status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webserver")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	paths, err := testBuilder().WriteFiles(dir)
+	if err != nil {
+		t.Fatalf("failed to write resource files: %v", err)
+	}
+	library, err := carddb.NewReloadingLibrary(dir, "", "en-US")
+	if err != nil {
+		t.Fatalf("failed to create library: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	statusHandler(library)(w, httptest.NewRequest("GET", "/status", nil))
+	var status carddb.ReloadStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := paths.Hashes(); !reflect.DeepEqual(status.Hashes, want) {
+		t.Errorf("status hashes mismatch. want %v, got %v", want, status.Hashes)
+	}
+}